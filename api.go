@@ -1,60 +1,86 @@
-package main
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"time"
-)
-
-type APIServer struct {
-	addr  string
-	store Store
-}
-
-func NewAPIServer(addr string, store Store) *APIServer {
-	return &APIServer{
-		addr:  addr,
-		store: store,
-	}
-}
-
-func (s *APIServer) Run() {
-	router := http.NewServeMux()
-	server := http.Server{
-		Addr:         s.addr,
-		Handler:      router,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-	}
-
-	subRouter := http.NewServeMux()
-	router.Handle("/api/v1/", http.StripPrefix("/api/v1", subRouter))
-
-	// registering services...
-
-	// task service...
-	tasksService := NewTasksService(s.store)
-	tasksService.RegisterRoutes(subRouter)
-
-	// user service...
-	userService := NewUserService(s.store)
-	userService.RegisterRoutes(subRouter)
-
-	// project service...
-	projectService := NewProjectService(s.store)
-	projectService.RegisterRoutes(subRouter)
-
-	// health check route...
-	// route "GET /" is not working !!!
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		response := map[string]string{"message": "server is up and running..."}
-
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(response)
-	})
-
-	log.Println("Server is listening on port", s.addr)
-	log.Fatal(server.ListenAndServe())
-}
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+type APIServer struct {
+	addr  string
+	store Store
+}
+
+func NewAPIServer(addr string, store Store) *APIServer {
+	return &APIServer{
+		addr:  addr,
+		store: store,
+	}
+}
+
+func (s *APIServer) Run() {
+	// keep revocation checks off the database for most requests
+	var store Store = s.store
+	if Envs.RevocationCacheTTL > 0 {
+		store = NewRevocationCache(s.store, Envs.RevocationCacheTTL)
+	}
+
+	if Envs.RevocationCleanupInterval > 0 {
+		go cleanupRevokedTokens(store, Envs.RevocationCleanupInterval)
+	}
+
+	server := http.Server{
+		Addr:         s.addr,
+		Handler:      newRouter(store),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	log.Println("Server is listening on port", s.addr)
+	log.Fatal(server.ListenAndServe())
+}
+
+// newRouter routes every endpoint of the API to the services on store.
+func newRouter(store Store) *http.ServeMux {
+	router := http.NewServeMux()
+	subRouter := http.NewServeMux()
+	router.Handle("/api/v1/", http.StripPrefix("/api/v1", subRouter))
+
+	// registering services...
+
+	// task service...
+	tasksService := NewTasksService(store)
+	tasksService.RegisterRoutes(subRouter)
+
+	// user service...
+	userService := NewUserService(store)
+	userService.RegisterRoutes(subRouter)
+
+	// auth service...
+	authService := NewAuthService(store)
+	authService.RegisterRoutes(subRouter)
+
+	// project service...
+	projectService := NewProjectService(store)
+	projectService.RegisterRoutes(subRouter)
+
+	// organization service...
+	orgService := NewOrganizationService(store)
+	orgService.RegisterRoutes(subRouter)
+
+	// public keys for verifying our tokens...
+	router.HandleFunc("GET /.well-known/jwks.json", HandleJWKS)
+
+	// health check route...
+	// route "GET /" is not working !!!
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]string{"message": "server is up and running..."}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	})
+
+	return router
+}
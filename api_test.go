@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestResponsesHaveNoSecrets calls every endpoint, successfully and not, and
+// scans the responses for password hashes.
+func TestResponsesHaveNoSecrets(t *testing.T) {
+	store := NewMemoryStore()
+	server := httptest.NewServer(newRouter(store))
+	defer server.Close()
+
+	var token string
+	call := func(t *testing.T, method, path string, payload any) []byte {
+		var body bytes.Buffer
+		if payload != nil {
+			if err := json.NewEncoder(&body).Encode(payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		req, err := http.NewRequest(method, server.URL+path, &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+
+		b, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertNoSecrets(t, method+" "+path, b)
+		return b
+	}
+
+	register := &RegisterUserPayload{FirstName: "bob", LastName: "cj", Email: "bob@gmail.com", Password: "5Vi64w^&"}
+
+	var tokens TokenResponse
+	if err := json.Unmarshal(call(t, http.MethodPost, "/api/v1/users/register", register), &tokens); err != nil {
+		t.Fatal(err)
+	}
+	token = tokens.Token
+
+	u, err := store.GetUserByEmail(context.Background(), register.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// admins get to call everything
+	if _, err := store.UpdateUserRole(context.Background(), "1", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	var p ProjectResponse
+	if err := json.Unmarshal(call(t, http.MethodPost, "/api/v1/projects", &CreateProjectPayload{Name: "website"}), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	var task TaskResponse
+	if err := json.Unmarshal(call(t, http.MethodPost, "/api/v1/tasks", &CreateTaskPayload{Name: "ship it", ProjectID: p.ID, AssignedTo: u.ID}), &task); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		method  string
+		path    string
+		payload any
+	}{
+		{http.MethodPost, "/api/v1/users/login", &LoginUserPayload{Email: register.Email, Password: register.Password}},
+		{http.MethodPost, "/api/v1/users/login", &LoginUserPayload{Email: register.Email, Password: "wrong"}},
+		{http.MethodPost, "/api/v1/users/register", register},
+		{http.MethodGet, "/api/v1/users/1", nil},
+		{http.MethodGet, "/api/v1/users/99", nil},
+		{http.MethodPut, "/api/v1/users/1/role", &UpdateUserRolePayload{Role: RoleAdmin}},
+		{http.MethodGet, "/api/v1/projects?include_total=true", nil},
+		{http.MethodGet, "/api/v1/projects/1", nil},
+		{http.MethodGet, "/api/v1/projects/1/members", nil},
+		{http.MethodPost, "/api/v1/projects/1/members", &AddProjectMemberPayload{UserID: 1, Role: ProjectRoleViewer}},
+		{http.MethodGet, "/api/v1/tasks/1", nil},
+		{http.MethodGet, "/api/v1/tasks?assigned_to=me", nil},
+		{http.MethodPatch, "/api/v1/tasks/1", map[string]any{"status": "IN_PROGRESS"}},
+		{http.MethodGet, "/api/v1/projects/1/tasks", nil},
+		{http.MethodGet, "/api/v1/projects/1/statuses", nil},
+		{http.MethodPost, "/api/v1/projects/1/statuses", &CreateProjectStatusPayload{Name: "BLOCKED", Category: StatusCategoryInProgress}},
+		{http.MethodGet, "/api/v1/projects/1/statuses/5", nil},
+		{http.MethodPatch, "/api/v1/projects/1/statuses/5", map[string]any{"name": "IN_REVIEW"}},
+		{http.MethodDelete, "/api/v1/projects/1/statuses/5", nil},
+		{http.MethodGet, "/api/v1/tasks?category=in_progress", nil},
+		{http.MethodGet, "/api/v1/projects/1/workflow", nil},
+		{http.MethodPut, "/api/v1/projects/1/workflow", &WorkflowPayload{Transitions: []WorkflowTransition{{FromStatusID: 2, ToStatusID: 1}}}},
+		{http.MethodGet, "/api/v1/orgs", nil},
+		{http.MethodGet, "/api/v1/orgs/1/members", nil},
+		{http.MethodPost, "/api/v1/orgs", &CreateOrganizationPayload{Name: "acme"}},
+		{http.MethodPost, "/api/v1/orgs/1/members", &AddOrganizationMemberPayload{UserID: 1, Role: OrgRoleMember}},
+		{http.MethodPost, "/api/v1/auth/refresh", &RefreshTokenPayload{RefreshToken: tokens.RefreshToken}},
+		{http.MethodGet, "/.well-known/jwks.json", nil},
+		{http.MethodDelete, "/api/v1/projects/1", nil},
+		{http.MethodPost, "/api/v1/auth/logout", nil},
+	}
+
+	for _, r := range requests {
+		t.Run(r.method+" "+r.path, func(t *testing.T) {
+			call(t, r.method, r.path, r.payload)
+		})
+	}
+}
+
+// assertNoSecrets fails when the JSON body b has a field named like a
+// password or a hash, or contains a bcrypt hash anywhere.
+func assertNoSecrets(t *testing.T, name string, b []byte) {
+	t.Helper()
+
+	if bytes.Contains(b, []byte("$2a$")) {
+		t.Errorf("%s: expected no bcrypt hash, got %s", name, b)
+	}
+
+	var v any
+	if len(bytes.TrimSpace(b)) == 0 {
+		return
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("%s: expected a JSON body, got %s", name, b)
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, field := range v {
+				if k := strings.ToLower(k); strings.Contains(k, "password") || strings.Contains(k, "hash") {
+					t.Errorf("%s: expected no %q field, got %s", name, k, b)
+				}
+				walk(field)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(v)
+}
@@ -1,103 +1,251 @@
-package main
-
-import (
-	"fmt"
-	"log"
-	"net/http"
-	"strconv"
-	"time"
-
-	"github.com/golang-jwt/jwt"
-	"golang.org/x/crypto/bcrypt"
-)
-
-func WithJWTAuth(handlerFunc http.HandlerFunc, store Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// get the token from req... from (Auth Header)
-		tokenString := GetTokenFromRequest(r)
-
-		// validate the token
-		token, err := validateJWT(tokenString)
-		if err != nil {
-			log.Println("error validating token: ", err)
-			WriteJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "permission denied"})
-			return
-		}
-
-		if !token.Valid {
-			log.Println("token is invalid")
-			WriteJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid jwt token"})
-			return
-		}
-
-		// get the user_id from the token
-		claims := token.Claims.(jwt.MapClaims)
-		id := claims["user_id"].(string)
-
-		u, err := store.GetUserByID(id)
-		if err != nil {
-			log.Println("error getting user by id: ", err)
-			WriteJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "permission denied"})
-			return
-		}
-
-		// case where jwt is valid, but credentials are not found in the db
-		if u == nil {
-			log.Println("user not found")
-			WriteJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid jwt token"})
-			return
-		}
-
-		// then, call the HandlerFunc and continue with the endpoint...
-		handlerFunc(w, r)
-	}
-}
-
-func GetTokenFromRequest(r *http.Request) string {
-	tokenAuth := r.Header.Get("Authorization")
-	tokenQuery := r.URL.Query().Get("token")
-
-	if tokenAuth != "" {
-		return tokenAuth
-	}
-
-	if tokenQuery != "" {
-		return tokenQuery
-	}
-
-	return ""
-}
-
-func validateJWT(t string) (*jwt.Token, error) {
-	secret := Envs.JWTSecret
-
-	return jwt.Parse(t, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
-}
-
-func HashPassword(pass string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-
-	return string(hash), nil
-}
-
-func CreateJWT(secret []byte, id int64) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":    strconv.Itoa(int(id)),
-		"expires_at": time.Now().Add(time.Hour * 24).Unix(),
-	})
-
-	tokenString, err := token.SignedString(secret)
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
-}
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errTokenMissingExpiry = errors.New("token has no expiry")
+var errTokenInvalidIssuer = errors.New("token has an invalid issuer")
+var errTokenInvalidAudience = errors.New("token has an invalid audience")
+var errTokenMissingID = errors.New("token has no id")
+var errTokenUnknownKey = errors.New("token was signed with an unknown key")
+
+const orgHeader = "X-Org-ID"
+
+// Claims are the claims carried by every token we issue. The user id lives
+// in the registered "sub" claim and "jti" identifies the token for revocation.
+// OrgID is the organization the token works in unless the request picks
+// another one through the X-Org-ID header.
+type Claims struct {
+	jwt.StandardClaims
+	OrgID int64 `json:"org_id,omitempty"`
+	// IssuedAtMicro is iat to the microsecond, so tokens issued within the
+	// second of a logout-all can tell whether they came before or after it
+	IssuedAtMicro int64 `json:"iat_us,omitempty"`
+}
+
+// issuedAt is when the token was issued, to the second for tokens without
+// IssuedAtMicro.
+func (c *Claims) issuedAt() time.Time {
+	if c.IssuedAtMicro != 0 {
+		return time.UnixMicro(c.IssuedAtMicro)
+	}
+	return time.Unix(c.IssuedAt, 0)
+}
+
+func WithJWTAuth(handlerFunc http.HandlerFunc, store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// get the token from req... from (Auth Header)
+		tokenString := GetTokenFromRequest(r)
+
+		// validate the token
+		token, err := validateJWT(tokenString)
+		if err != nil {
+			log.Println("error validating token: ", err)
+			WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+			return
+		}
+
+		if !token.Valid {
+			log.Println("token is invalid")
+			WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, "invalid jwt token")
+			return
+		}
+
+		// get the user_id from the token
+		claims := token.Claims.(*Claims)
+		id := claims.Subject
+
+		// check the token wasn't revoked by a logout
+		revoked, err := store.IsTokenRevoked(r.Context(), claims.Id)
+		if err != nil {
+			log.Println("error checking token revocation: ", err)
+			WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+			return
+		}
+
+		if revoked {
+			log.Println("token is revoked")
+			WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, "invalid jwt token")
+			return
+		}
+
+		u, err := store.GetUserByID(r.Context(), id)
+		if err != nil {
+			log.Println("error getting user by id: ", err)
+			WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+			return
+		}
+
+		// case where jwt is valid, but credentials are not found in the db
+		if u == nil {
+			log.Println("user not found")
+			WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, "invalid jwt token")
+			return
+		}
+
+		// case where the user logged out everywhere after this token was issued
+		if u.TokensValidAfter != nil && !claims.issuedAt().After(*u.TokensValidAfter) {
+			log.Println("token was issued before the last logout-all")
+			WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, "invalid jwt token")
+			return
+		}
+
+		ctx := WithAuth(r.Context(), u, claims)
+
+		// select the active organization, the header wins over the claim
+		orgID := claims.OrgID
+		if h := r.Header.Get(orgHeader); h != "" {
+			orgID, err = strconv.ParseInt(h, 10, 64)
+			if err != nil || orgID <= 0 {
+				WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "invalid "+orgHeader+" header")
+				return
+			}
+		}
+
+		if orgID != 0 {
+			_, err := store.GetOrganizationMember(r.Context(), orgID, u.ID)
+			if errors.Is(err, ErrNotFound) {
+				log.Printf("user %d is not a member of organization %d", u.ID, orgID)
+				WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+				return
+			}
+
+			if err != nil {
+				log.Println("error getting organization member: ", err)
+				WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error checking organization membership")
+				return
+			}
+
+			ctx = WithOrg(ctx, orgID)
+		}
+
+		// then, call the HandlerFunc and continue with the endpoint...
+		handlerFunc(w, r.WithContext(ctx))
+	}
+}
+
+func GetTokenFromRequest(r *http.Request) string {
+	tokenAuth := r.Header.Get("Authorization")
+	tokenQuery := r.URL.Query().Get("token")
+
+	if tokenAuth != "" {
+		return tokenAuth
+	}
+
+	if tokenQuery != "" {
+		return tokenQuery
+	}
+
+	return ""
+}
+
+func validateJWT(t string) (*jwt.Token, error) {
+	// exp, nbf and iat are checked by the parser through StandardClaims.Valid
+	token, err := jwt.ParseWithClaims(t, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		// tokens issued before kids were introduced have none, they can only
+		// have been signed by the current key
+		key := Keys.Current()
+		if kid, ok := t.Header["kid"]; ok {
+			kid, _ := kid.(string)
+			if key, ok = Keys.Lookup(kid); !ok {
+				return nil, errTokenUnknownKey
+			}
+		}
+
+		// pinning the algorithm to the key stops e.g. an HS256 token "signed"
+		// with our RSA public key from being accepted
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		return key.verifyKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(*Claims)
+
+	// the parser treats exp as optional, we don't
+	if claims.ExpiresAt == 0 {
+		return nil, errTokenMissingExpiry
+	}
+
+	if !claims.VerifyIssuer(Envs.JWTIssuer, true) {
+		return nil, errTokenInvalidIssuer
+	}
+
+	if !claims.VerifyAudience(Envs.JWTAudience, true) {
+		return nil, errTokenInvalidAudience
+	}
+
+	if claims.Id == "" {
+		return nil, errTokenMissingID
+	}
+
+	return token, nil
+}
+
+func HashPassword(pass string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func ComparePasswords(hashed, plain string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain))
+	return err == nil
+}
+
+// CreateJWT issues a token for user id working in organization orgID, which
+// may be 0 for users not belonging to any organization.
+func CreateJWT(key *SigningKey, id, orgID int64) (string, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := newClaims(id, jti, time.Now())
+	claims.OrgID = orgID
+
+	return signJWT(key, claims)
+}
+
+func newClaims(id int64, jti string, now time.Time) *Claims {
+	return &Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Subject:   strconv.FormatInt(id, 10),
+			Issuer:    Envs.JWTIssuer,
+			Audience:  Envs.JWTAudience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(Envs.JWTExpiration).Unix(),
+		},
+		IssuedAtMicro: now.UnixMicro(),
+	}
+}
+
+func signJWT(key *SigningKey, claims *Claims) (string, error) {
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.signKey)
+	if err != nil {
+		return "", err
+	}
+
+	return tokenString, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidateJWT(t *testing.T) {
+	key := Keys.Current()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		claims  func() *Claims
+		wantErr bool
+	}{
+		{
+			name:    "should accept a freshly issued token",
+			claims:  func() *Claims { return newClaims(1, "jti", now) },
+			wantErr: false,
+		},
+		{
+			name: "should reject an expired token",
+			claims: func() *Claims {
+				return newClaims(1, "jti", now.Add(-Envs.JWTExpiration-time.Minute))
+			},
+			wantErr: true,
+		},
+		{
+			name: "should reject a token that is not valid yet",
+			claims: func() *Claims {
+				c := newClaims(1, "jti", now)
+				c.NotBefore = now.Add(time.Hour).Unix()
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "should reject a token without an expiry",
+			claims: func() *Claims {
+				c := newClaims(1, "jti", now)
+				c.ExpiresAt = 0
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "should reject a token without an id",
+			claims: func() *Claims {
+				c := newClaims(1, "jti", now)
+				c.Id = ""
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "should reject a token from another issuer",
+			claims: func() *Claims {
+				c := newClaims(1, "jti", now)
+				c.Issuer = "someone-else"
+				return c
+			},
+			wantErr: true,
+		},
+		{
+			name: "should reject a token for another audience",
+			claims: func() *Claims {
+				c := newClaims(1, "jti", now)
+				c.Audience = "another-api"
+				return c
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := signJWT(key, tt.claims())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = validateJWT(token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateJWT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("should reject a token signed with another secret", func(t *testing.T) {
+		token, err := signJWT(NewHMACKey(key.ID, []byte("not-the-secret")), newClaims(1, "jti", now))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := validateJWT(token); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
+
+func TestWithJWTAuth(t *testing.T) {
+	ms := &MockStore{users: []*User{{ID: 1}}}
+	handler := WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}, ms)
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		token, err := signJWT(Keys.Current(), newClaims(1, "jti", time.Now().Add(-48*time.Hour)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should accept a valid token", func(t *testing.T) {
+		token, err := CreateJWT(Keys.Current(), 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should pass the authenticated user to the handler", func(t *testing.T) {
+		ms := &MockStore{users: []*User{{ID: 42, Email: "bob@gmail.com"}}}
+
+		var got *User
+		var claims *Claims
+		handler := WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = UserFromContext(r.Context())
+			claims, _ = ClaimsFromContext(r.Context())
+		}, ms)
+
+		token, err := CreateJWT(Keys.Current(), 42, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+
+		handler(httptest.NewRecorder(), req)
+
+		if got == nil || got.Email != "bob@gmail.com" {
+			t.Errorf("expected user bob@gmail.com in the context, got %+v", got)
+		}
+
+		if claims == nil || claims.Subject != "42" {
+			t.Errorf("expected claims for subject 42 in the context, got %+v", claims)
+		}
+	})
+}
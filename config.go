@@ -1,35 +1,117 @@
-package main
-
-import (
-	"fmt"
-	"os"
-)
-
-type Config struct {
-	Port       string
-	DBUser     string
-	DBPassword string
-	DBAddress  string
-	DBName     string
-	JWTSecret  string
-}
-
-var Envs = initConfig()
-
-func initConfig() Config {
-	return Config{
-		Port:       getEnv("PORT", ":8080"),
-		DBUser:     getEnv("DB_USER", "root"),
-		DBPassword: getEnv("DB_PASSWORD", "password"),
-		DBAddress:  fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
-		DBName:     getEnv("DB_NAME", "projectmanager"),
-		JWTSecret:  getEnv("JWT_SECRET", "J4/*j#@h+65v"),
-	}
-}
-
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
-	}
-	return fallback
-}
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	Port       string
+	DBUser     string
+	DBPassword string
+	DBAddress  string
+	DBName     string
+	JWTSecret  string
+
+	// mysql, postgres, sqlite or memory
+	StoreDriver string
+	SQLitePath  string
+	// sslmode of Postgres connections
+	DBSSLMode string
+	// deadline of every database call, 0 disables it
+	DBQueryTimeout time.Duration
+
+	// largest request body accepted
+	MaxBodyBytes int64
+
+	// HS256 signs with JWTSecret, RS256 and EdDSA with the PEM encoded
+	// private key in JWTPrivateKeyFile
+	JWTSigningMethod  string
+	JWTPrivateKeyFile string
+	JWTKeyID          string
+	JWTTrustedKeys    string
+
+	// registered claims used when issuing and validating tokens
+	JWTExpiration time.Duration
+	JWTIssuer     string
+	JWTAudience   string
+
+	RefreshTokenExpiration time.Duration
+
+	// how long revocation lookups are cached in memory, 0 disables the cache
+	RevocationCacheTTL        time.Duration
+	RevocationCleanupInterval time.Duration
+}
+
+var Envs = initConfig()
+
+func initConfig() Config {
+	return Config{
+		Port:       getEnv("PORT", ":8080"),
+		DBUser:     getEnv("DB_USER", "root"),
+		DBPassword: getEnv("DB_PASSWORD", "password"),
+		DBAddress:  fmt.Sprintf("%s:%s", getEnv("DB_HOST", "127.0.0.1"), getEnv("DB_PORT", "3306")),
+		DBName:     getEnv("DB_NAME", "projectmanager"),
+		JWTSecret:  getEnv("JWT_SECRET", "J4/*j#@h+65v"),
+
+		StoreDriver: getEnv("STORE_DRIVER", "mysql"),
+		SQLitePath:  getEnv("SQLITE_PATH", "projectmanager.db"),
+		DBSSLMode:   getEnv("DB_SSLMODE", "disable"),
+
+		DBQueryTimeout: getEnvAsDuration("DB_QUERY_TIMEOUT", time.Second*5),
+
+		MaxBodyBytes: getEnvAsInt("MAX_BODY_BYTES", 1<<20),
+
+		JWTSigningMethod:  getEnv("JWT_SIGNING_METHOD", "HS256"),
+		JWTPrivateKeyFile: getEnv("JWT_PRIVATE_KEY_FILE", ""),
+		JWTKeyID:          getEnv("JWT_KEY_ID", ""),
+		JWTTrustedKeys:    getEnv("JWT_TRUSTED_KEYS", ""),
+
+		JWTExpiration: getEnvAsDuration("JWT_EXPIRATION", time.Minute*15),
+		JWTIssuer:     getEnv("JWT_ISSUER", "projectmanager"),
+		JWTAudience:   getEnv("JWT_AUDIENCE", "projectmanager-api"),
+
+		RefreshTokenExpiration: getEnvAsDuration("REFRESH_TOKEN_EXPIRATION", time.Hour*24*7),
+
+		RevocationCacheTTL:        getEnvAsDuration("REVOCATION_CACHE_TTL", time.Second*30),
+		RevocationCleanupInterval: getEnvAsDuration("REVOCATION_CLEANUP_INTERVAL", time.Hour),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func getEnvAsInt(key string, fallback int64) int64 {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n <= 0 {
+		log.Fatalf("invalid positive integer for %s: %q", key, value)
+	}
+
+	return n
+}
+
+func getEnvAsDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid duration for %s: %v", key, err)
+	}
+
+	return d
+}
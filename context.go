@@ -0,0 +1,43 @@
+package main
+
+import "context"
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	claimsContextKey
+	orgContextKey
+)
+
+// WithAuth returns a copy of ctx carrying the authenticated user and the
+// claims of the token they authenticated with. WithJWTAuth calls it for every
+// request it lets through.
+func WithAuth(ctx context.Context, u *User, claims *Claims) context.Context {
+	ctx = context.WithValue(ctx, userContextKey, u)
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// UserFromContext returns the authenticated user of the request.
+func UserFromContext(ctx context.Context) (*User, bool) {
+	u, ok := ctx.Value(userContextKey).(*User)
+	return u, ok && u != nil
+}
+
+// ClaimsFromContext returns the claims of the request's token.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsContextKey).(*Claims)
+	return c, ok && c != nil
+}
+
+// WithOrg returns a copy of ctx carrying the active organization, which
+// WithJWTAuth only sets after checking the user belongs to it.
+func WithOrg(ctx context.Context, orgID int64) context.Context {
+	return context.WithValue(ctx, orgContextKey, orgID)
+}
+
+// OrgFromContext returns the active organization of the request.
+func OrgFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(orgContextKey).(int64)
+	return id, ok && id != 0
+}
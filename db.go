@@ -1,108 +1,83 @@
-package main
-
-import (
-	"database/sql"
-	"log"
-
-	"github.com/go-sql-driver/mysql"
-)
-
-type MySQLStorage struct {
-	db *sql.DB
-}
-
-func NewMySQLStorage(cfg mysql.Config) *MySQLStorage {
-	db, err := sql.Open("mysql", cfg.FormatDSN())
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if err := db.Ping(); err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Connected to MySQL!")
-	return &MySQLStorage{
-		db: db,
-	}
-}
-
-func (s *MySQLStorage) Init() (*sql.DB, error) {
-	// initialize the tables
-	if err := s.createProjectsTable(); err != nil {
-		return nil, err
-	}
-
-	if err := s.createUsersTable(); err != nil {
-		return nil, err
-	}
-
-	if err := s.createTasksTable(); err != nil {
-		return nil, err
-	}
-
-	return s.db, nil
-}
-
-func (s *MySQLStorage) createProjectsTable() error {
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS projects (
-			id INT UNSIGNED NOT NULL AUTO_INCREMENT,
-			name VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-			
-			PRIMARY KEY (id)
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8;
-	`)
-
-	if err != nil {
-		return err
-	}
-
-	return err
-}
-
-func (s *MySQLStorage) createUsersTable() error {
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id INT UNSIGNED NOT NULL AUTO_INCREMENT,
-			email VARCHAR(255) NOT NULL,
-			first_name VARCHAR(255) NOT NULL,
-			last_name VARCHAR(255) NOT NULL,
-			password VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-
-			PRIMARY KEY (id),
-			UNIQUE KEY (email)
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8;
-	`)
-
-	if err != nil {
-		return err
-	}
-
-	return err
-}
-
-func (s *MySQLStorage) createTasksTable() error {
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS tasks (
-			id INT UNSIGNED NOT NULL AUTO_INCREMENT,
-			name VARCHAR(255) NOT NULL,
-			status ENUM('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') NOT NULL DEFAULT 'TODO',
-			project_id INT UNSIGNED NOT NULL,
-			assigned_to INT UNSIGNED NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-
-			PRIMARY KEY (id),
-			FOREIGN KEY (assigned_to) REFERENCES users(id),
-			FOREIGN KEY (project_id) REFERENCES projects(id)	
-		) ENGINE=InnoDB DEFAULT CHARSET=utf8;
-	`)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type MySQLStorage struct {
+	db *sql.DB
+}
+
+func NewMySQLStorage(cfg mysql.Config) *MySQLStorage {
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Connected to MySQL!")
+	return &MySQLStorage{
+		db: db,
+	}
+}
+
+// Init brings the schema up to date by applying every pending migration,
+// see migrate.go and the migrations directory.
+func (s *MySQLStorage) Init() (*sql.DB, error) {
+	return migrateUp(s.db, MySQLMigrations)
+}
+
+func (s *MySQLStorage) Migrator() (*Migrator, error) {
+	return NewMigrator(s.db, MySQLMigrations)
+}
+
+func migrateUp(db *sql.DB, dialect MigrationDialect) (*sql.DB, error) {
+	m, err := NewMigrator(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := m.Up()
+	if err != nil {
+		return nil, err
+	}
+
+	if n > 0 {
+		log.Printf("Applied %d migrations", n)
+	}
+
+	return db, nil
+}
+
+// isMySQLDeadlock reports whether err is InnoDB choosing the transaction as
+// a deadlock victim, which rolled it back.
+func isMySQLDeadlock(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1213
+}
+
+// mysqlErrorKind classifies the constraint violations of MySQL.
+func mysqlErrorKind(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return nil
+	}
+
+	switch mysqlErr.Number {
+	case 1062: // duplicate entry
+		return ErrConflict
+	case 1451, 1452: // row is referenced, referenced row is missing
+		return ErrForeignKey
+	case 1048, 1264, 1265, 1366, 1406, 3819: // null, out of range, not in the ENUM, wrong type, too long, check
+		return ErrValidation
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// The errors Store returns, whatever the backend, when a call fails because
+// of what it was asked to do. Match them with errors.Is.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("already exists")
+	ErrForeignKey = errors.New("references a row that does not exist")
+	ErrValidation = errors.New("has an invalid value")
+)
+
+var errTaskStatusChanged = &StoreError{Kind: ErrConflict, Message: "task status changed meanwhile"}
+
+var errStatusHasTasks = &StoreError{Kind: ErrConflict, Message: "status still has tasks"}
+
+// StoreError is one of the errors above caused by Err, the error of the
+// database driver. Only Kind and Message make it into responses, the
+// driver's message may tell more about the schema than clients should know.
+type StoreError struct {
+	Kind error
+	// Message replaces the default "<resource> <Kind>" of WriteStoreError
+	Message string
+	Err     error
+}
+
+func (e *StoreError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Kind.Error()
+	}
+
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *StoreError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// WriteStoreError answers a request whose Store call on resource, e.g.
+// "task", failed with err. ErrNotFound becomes a 404, ErrConflict a 409,
+// ErrForeignKey a 422 and ErrValidation a 400. Anything else is logged and
+// answered with a 500 that doesn't repeat it.
+func WriteStoreError(w http.ResponseWriter, r *http.Request, err error, resource string) {
+	var kind error
+	var status int
+	var code string
+	switch {
+	case errors.Is(err, ErrNotFound):
+		kind, status, code = ErrNotFound, http.StatusNotFound, CodeNotFound
+	case errors.Is(err, ErrConflict):
+		kind, status, code = ErrConflict, http.StatusConflict, CodeConflict
+	case errors.Is(err, ErrForeignKey):
+		kind, status, code = ErrForeignKey, http.StatusUnprocessableEntity, CodeInvalidReference
+	case errors.Is(err, ErrValidation):
+		kind, status, code = ErrValidation, http.StatusBadRequest, CodeInvalidRequest
+	default:
+		log.Printf("error accessing %s: %v", resource, err)
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "")
+		return
+	}
+
+	msg := resource + " " + kind.Error()
+	var storeErr *StoreError
+	if errors.As(err, &storeErr) && storeErr.Message != "" {
+		msg = storeErr.Message
+	}
+
+	WriteProblem(w, r, status, code, msg)
+}
+
+// The codes of error responses. Unlike the detail they never change, so
+// clients can switch on them.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeValidationFailed   = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidToken       = "invalid_token"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeInvalidReference   = "invalid_reference"
+	CodeInvalidTransition  = "invalid_transition"
+	CodeBodyTooLarge       = "body_too_large"
+	CodeUnsupportedMedia   = "unsupported_media_type"
+	CodeInternal           = "internal_error"
+)
+
+var problemTitles = map[string]string{
+	CodeInvalidRequest:     "Invalid request",
+	CodeValidationFailed:   "Validation failed",
+	CodeUnauthorized:       "Authentication required",
+	CodeInvalidCredentials: "Invalid credentials",
+	CodeInvalidToken:       "Invalid token",
+	CodeForbidden:          "Forbidden",
+	CodeNotFound:           "Not found",
+	CodeConflict:           "Conflict",
+	CodeInvalidReference:   "Invalid reference",
+	CodeInvalidTransition:  "Invalid transition",
+	CodeBodyTooLarge:       "Request body too large",
+	CodeUnsupportedMedia:   "Unsupported media type",
+	CodeInternal:           "Internal server error",
+}
+
+// Problem is an RFC 7807 problem details object, the body of every error
+// response.
+type Problem struct {
+	// Type is "/problems/<code>", relative to the API
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// Errors lists the invalid fields of a validation_failed problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is what is wrong with one field of a request body. Code is
+// one of the Field* constants.
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// WriteProblem answers r with the problem code, explained by detail.
+func WriteProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblem(w, r, &Problem{Status: status, Code: code, Detail: detail})
+}
+
+// WriteValidationProblem answers r with a validation_failed problem listing
+// the fields of err, or with an invalid_request one when err isn't a
+// *ValidationError.
+func WriteValidationProblem(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	writeProblem(w, r, &Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: validationErr.Error(),
+		Errors: validationErr.Fields,
+	})
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Type = "/problems/" + p.Code
+	p.Title = problemTitles[p.Code]
+	// the path before any prefix was stripped for routing
+	p.Instance, _, _ = strings.Cut(r.RequestURI, "?")
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
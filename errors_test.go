@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteStoreError(t *testing.T) {
+	driverErr := errors.New("Error 1062 (23000): Duplicate entry 'bob@gmail.com' for key 'users.email'")
+
+	tests := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{
+			name:    "should answer a missing row with 404",
+			err:     ErrNotFound,
+			status:  http.StatusNotFound,
+			message: "task not found",
+		},
+		{
+			name:    "should answer a duplicate with 409 without the driver error",
+			err:     &StoreError{Kind: ErrConflict, Err: driverErr},
+			status:  http.StatusConflict,
+			message: "task already exists",
+		},
+		{
+			name:    "should answer a missing reference with 422",
+			err:     &StoreError{Kind: ErrForeignKey, Err: driverErr},
+			status:  http.StatusUnprocessableEntity,
+			message: "task references a row that does not exist",
+		},
+		{
+			name:    "should answer an invalid value with 400",
+			err:     &StoreError{Kind: ErrValidation, Err: driverErr},
+			status:  http.StatusBadRequest,
+			message: "task has an invalid value",
+		},
+		{
+			name:    "should prefer the message of the error",
+			err:     errStatusHasTasks,
+			status:  http.StatusConflict,
+			message: errStatusHasTasks.Message,
+		},
+		{
+			name:    "should hide unknown errors behind a 500",
+			err:     driverErr,
+			status:  http.StatusInternalServerError,
+			message: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/tasks/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			WriteStoreError(rr, req, tt.err, "task")
+
+			if rr.Code != tt.status {
+				t.Errorf("expected status code %d, got %d", tt.status, rr.Code)
+			}
+
+			var response Problem
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			if response.Status != tt.status || response.Detail != tt.message {
+				t.Errorf("expected status %d and detail %q, got %+v", tt.status, tt.message, response)
+			}
+		})
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) Problem {
+		if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("expected content type application/problem+json, got %q", ct)
+		}
+
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		return p
+	}
+
+	t.Run("should describe the problem", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/tasks/1?x=y", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RequestURI = "/api/v1/tasks/1?x=y"
+
+		rr := httptest.NewRecorder()
+		WriteProblem(rr, req, http.StatusForbidden, CodeForbidden, "forbidden")
+
+		want := Problem{
+			Type:     "/problems/forbidden",
+			Title:    "Forbidden",
+			Status:   http.StatusForbidden,
+			Detail:   "forbidden",
+			Instance: "/api/v1/tasks/1",
+			Code:     CodeForbidden,
+		}
+
+		if p := decode(t, rr); !reflect.DeepEqual(p, want) {
+			t.Errorf("expected %+v, got %+v", want, p)
+		}
+	})
+
+	t.Run("should list the invalid fields", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/users/register", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		WriteValidationProblem(rr, req, validateLoginPayload(&LoginUserPayload{}))
+
+		p := decode(t, rr)
+		if rr.Code != http.StatusBadRequest || p.Code != CodeValidationFailed {
+			t.Errorf("expected a %d %s problem, got %d %+v", http.StatusBadRequest, CodeValidationFailed, rr.Code, p)
+		}
+
+		want := []FieldError{
+			{Field: "email", Code: FieldRequired, Detail: "email is required"},
+			{Field: "password", Code: FieldRequired, Detail: "password is required"},
+		}
+		if !reflect.DeepEqual(p.Errors, want) {
+			t.Errorf("expected field errors %+v, got %+v", want, p.Errors)
+		}
+	})
+
+	t.Run("should answer other errors as invalid requests", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/auth/refresh", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		WriteValidationProblem(rr, req, errAssigneeNotMember)
+
+		if p := decode(t, rr); p.Code != CodeInvalidRequest || len(p.Errors) != 0 {
+			t.Errorf("expected a %s problem without field errors, got %+v", CodeInvalidRequest, p)
+		}
+	})
+}
+
+func TestStoreErrorResponses(t *testing.T) {
+	newStore := func(method string, err error) *MockStore {
+		return &MockStore{
+			users:    []*User{{ID: 1, Role: RoleMember}},
+			projects: []*Project{{ID: 1, OrgID: testOrgID}},
+			members:  []*ProjectMember{{ProjectID: 1, UserID: 1, Role: ProjectRoleOwner}},
+			errs:     map[string]error{method: err},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		store   *MockStore
+		method  string
+		path    string
+		body    any
+		handler func(Store) http.HandlerFunc
+		want    int
+	}{
+		{
+			name:    "should return 404 for an unknown task",
+			store:   newStore("", nil),
+			method:  http.MethodGet,
+			path:    "/tasks/99",
+			handler: func(s Store) http.HandlerFunc { return NewTasksService(s).HandleGetTask },
+			want:    http.StatusNotFound,
+		},
+		{
+			name:    "should return 404 for an unknown project",
+			store:   &MockStore{users: []*User{{ID: 1, Role: RoleAdmin}}},
+			method:  http.MethodGet,
+			path:    "/projects/99",
+			handler: func(s Store) http.HandlerFunc { return NewProjectService(s).HandleProjectGet },
+			want:    http.StatusNotFound,
+		},
+		{
+			name:    "should return 404 for an unknown user",
+			store:   &MockStore{users: []*User{{ID: 1, Role: RoleAdmin}}},
+			method:  http.MethodGet,
+			path:    "/users/99",
+			handler: func(s Store) http.HandlerFunc { return NewUserService(s).HandleUserGet },
+			want:    http.StatusNotFound,
+		},
+		{
+			name:    "should return 409 for a duplicate email",
+			store:   newStore("CreateUser", &StoreError{Kind: ErrConflict}),
+			method:  http.MethodPost,
+			path:    "/users/register",
+			body:    &RegisterUserPayload{FirstName: "bob", LastName: "cj", Email: "bob@gmail.com", Password: "5Vi64w^&"},
+			handler: func(s Store) http.HandlerFunc { return NewUserService(s).HandleUserRegister },
+			want:    http.StatusConflict,
+		},
+		{
+			name:    "should return 422 for a task assigned to an unknown user",
+			store:   newStore("CreateTask", &StoreError{Kind: ErrForeignKey}),
+			method:  http.MethodPost,
+			path:    "/tasks",
+			body:    &CreateTaskPayload{Name: "write docs", ProjectID: 1, AssignedTo: 1},
+			handler: func(s Store) http.HandlerFunc { return NewTasksService(s).HandleCreateTask },
+			want:    http.StatusUnprocessableEntity,
+		},
+		{
+			name:    "should return 500 without the driver error",
+			store:   newStore("GetTask", errors.New("dial tcp 10.0.0.5:3306: connection refused")),
+			method:  http.MethodGet,
+			path:    "/tasks/1",
+			handler: func(s Store) http.HandlerFunc { return NewTasksService(s).HandleGetTask },
+			want:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			if tt.body != nil {
+				if err := json.NewEncoder(&body).Encode(tt.body); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			req, err := http.NewRequest(tt.method, tt.path, &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req = authenticate(req, tt.store.users[0])
+
+			rr := httptest.NewRecorder()
+			router := http.NewServeMux()
+
+			router.HandleFunc("GET /tasks/{task_id}", tt.handler(tt.store))
+			router.HandleFunc("POST /tasks", tt.handler(tt.store))
+			router.HandleFunc("GET /projects/{project_id}", tt.handler(tt.store))
+			router.HandleFunc("DELETE /projects/{project_id}", tt.handler(tt.store))
+			router.HandleFunc("GET /users/{user_id}", tt.handler(tt.store))
+			router.HandleFunc("POST /users/register", tt.handler(tt.store))
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status code %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+
+			if strings.Contains(rr.Body.String(), "tcp") {
+				t.Errorf("expected the driver error to stay out of the response, got %s", rr.Body)
+			}
+		})
+	}
+}
@@ -2,10 +2,15 @@ module github.com/rohanhonnakatti/go-docker-jwt-mysql-crud
 
 go 1.22.0
 
+require (
+	github.com/go-sql-driver/mysql v1.8.0
+	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+	golang.org/x/crypto v0.23.0
+)
+
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/go-sql-driver/mysql v1.8.0 // indirect
-	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
 	github.com/gorilla/mux v1.8.1 // indirect
-	golang.org/x/crypto v0.23.0 // indirect
 )
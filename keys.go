@@ -0,0 +1,307 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is the key tokens are signed and verified with. For HS256 both
+// sides use the shared secret, for RS256 and EdDSA only the public half is
+// needed to verify, which is what the JWKS endpoint publishes.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// KeyRing holds every key we still accept tokens from, identified by kid,
+// and the one new tokens are signed with. It's built once from the config
+// and never changes, so every instance agrees on it: rotating means
+// restarting with the new key as the signing key and the old one in
+// JWT_TRUSTED_KEYS, then dropping it once all tokens it signed expired.
+type KeyRing struct {
+	current *SigningKey
+	keys    map[string]*SigningKey
+}
+
+var Keys = mustLoadKeyRing(Envs)
+
+func NewKeyRing(current *SigningKey, trusted ...*SigningKey) *KeyRing {
+	kr := &KeyRing{
+		current: current,
+		keys:    map[string]*SigningKey{current.ID: current},
+	}
+
+	for _, key := range trusted {
+		kr.keys[key.ID] = key
+	}
+
+	return kr
+}
+
+// Current returns the key new tokens are signed with.
+func (kr *KeyRing) Current() *SigningKey {
+	return kr.current
+}
+
+// Lookup returns the trusted key with the given kid.
+func (kr *KeyRing) Lookup(kid string) (*SigningKey, bool) {
+	key, ok := kr.keys[kid]
+	return key, ok
+}
+
+// JWKS returns the public keys of the ring, ordered by kid.
+func (kr *KeyRing) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range kr.keys {
+		if jwk, ok := key.JWK(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
+
+func mustLoadKeyRing(cfg Config) *KeyRing {
+	kr, err := loadKeyRing(cfg)
+	if err != nil {
+		log.Fatalf("error loading jwt keys: %v", err)
+	}
+
+	return kr
+}
+
+// loadKeyRing builds the ring from the signing key settings plus
+// JWT_TRUSTED_KEYS, a comma separated list of kid:alg:path entries for
+// retired keys. For HS256 the file holds the secret, otherwise a PEM encoded
+// private or public key.
+func loadKeyRing(cfg Config) (*KeyRing, error) {
+	current, err := loadSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var trusted []*SigningKey
+	for _, entry := range strings.Split(cfg.JWTTrustedKeys, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid trusted key %q, expected kid:alg:path", entry)
+		}
+
+		b, err := os.ReadFile(parts[2])
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := parseVerificationKey(parts[0], parts[1], b)
+		if err != nil {
+			return nil, fmt.Errorf("trusted key %s: %w", parts[0], err)
+		}
+
+		if key.ID == current.ID {
+			return nil, fmt.Errorf("trusted key %s has the same kid as the signing key", key.ID)
+		}
+
+		trusted = append(trusted, key)
+	}
+
+	return NewKeyRing(current, trusted...), nil
+}
+
+func loadSigningKey(cfg Config) (*SigningKey, error) {
+	switch cfg.JWTSigningMethod {
+	case jwt.SigningMethodHS256.Alg():
+		return NewHMACKey(cfg.JWTKeyID, []byte(cfg.JWTSecret)), nil
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		pem, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return parsePrivateKeyPEM(cfg.JWTKeyID, cfg.JWTSigningMethod, pem)
+	default:
+		return nil, fmt.Errorf("unsupported signing method: %s", cfg.JWTSigningMethod)
+	}
+}
+
+func NewHMACKey(id string, secret []byte) *SigningKey {
+	if id == "" {
+		id = "default"
+	}
+
+	return &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// parsePrivateKeyPEM builds an RS256 or EdDSA key from a PEM encoded private
+// key. Without an explicit id the RFC 7638 thumbprint of the public key is used.
+func parsePrivateKeyPEM(id, alg string, pem []byte) (*SigningKey, error) {
+	key := &SigningKey{ID: id}
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.signKey = priv
+		key.verifyKey = &priv.PublicKey
+	case jwt.SigningMethodEdDSA.Alg():
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, err
+		}
+		key.Method = jwt.SigningMethodEdDSA
+		key.signKey = priv
+		key.verifyKey = priv.(ed25519.PrivateKey).Public()
+	default:
+		return nil, fmt.Errorf("unsupported signing method: %s", alg)
+	}
+
+	if key.ID == "" {
+		thumbprint, err := key.thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+
+	return key, nil
+}
+
+// parseVerificationKey accepts anything a retired key may have been kept as:
+// an HMAC secret, a private key PEM, or just the public key PEM.
+func parseVerificationKey(id, alg string, b []byte) (*SigningKey, error) {
+	if alg == jwt.SigningMethodHS256.Alg() {
+		return NewHMACKey(id, []byte(strings.TrimSpace(string(b)))), nil
+	}
+
+	if strings.Contains(string(b), "PRIVATE KEY") {
+		return parsePrivateKeyPEM(id, alg, b)
+	}
+
+	key := &SigningKey{ID: id}
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(b)
+		if err != nil {
+			return nil, err
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.verifyKey = pub
+	case jwt.SigningMethodEdDSA.Alg():
+		pub, err := jwt.ParseEdPublicKeyFromPEM(b)
+		if err != nil {
+			return nil, err
+		}
+		key.Method = jwt.SigningMethodEdDSA
+		key.verifyKey = pub
+	default:
+		return nil, fmt.Errorf("unsupported signing method: %s", alg)
+	}
+
+	return key, nil
+}
+
+// JWK is the public part of a signing key as published in the JWKS.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK reports false for symmetric keys, which must never be published.
+func (k *SigningKey) JWK() (JWK, bool) {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	default:
+		return JWK{}, false
+	}
+
+	return jwk, true
+}
+
+func (k *SigningKey) thumbprint() (string, error) {
+	jwk, ok := k.JWK()
+	if !ok {
+		return "", fmt.Errorf("no thumbprint for %s keys", k.Method.Alg())
+	}
+
+	// only the required members, in lexicographic order
+	var members interface{}
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// HandleJWKS publishes the public signing keys so other services can verify
+// our tokens without holding any secret. Retired keys stay listed until they
+// are removed from the ring.
+func HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	WriteJSON(w, http.StatusOK, Keys.JWKS())
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// useKeyRing swaps the process wide key ring for the duration of a test.
+func useKeyRing(t *testing.T, kr *KeyRing) {
+	t.Helper()
+
+	prev := Keys
+	Keys = kr
+	t.Cleanup(func() { Keys = prev })
+}
+
+func useSigningKey(t *testing.T, key *SigningKey) {
+	t.Helper()
+	useKeyRing(t, NewKeyRing(key))
+}
+
+func writePrivateKeyPEM(t *testing.T, priv interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	b := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func newRSAKey(t *testing.T, id string) *SigningKey {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := loadSigningKey(Config{
+		JWTSigningMethod:  "RS256",
+		JWTPrivateKeyFile: writePrivateKeyPEM(t, priv),
+		JWTKeyID:          id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func newEdDSAKey(t *testing.T, id string) *SigningKey {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := loadSigningKey(Config{
+		JWTSigningMethod:  "EdDSA",
+		JWTPrivateKeyFile: writePrivateKeyPEM(t, priv),
+		JWTKeyID:          id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	keys := map[string]*SigningKey{
+		"RS256": newRSAKey(t, ""),
+		"EdDSA": newEdDSAKey(t, ""),
+	}
+
+	for alg, key := range keys {
+		t.Run("should issue and validate "+alg+" tokens", func(t *testing.T) {
+			useSigningKey(t, key)
+
+			token, err := CreateJWT(key, 1, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := validateJWT(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if parsed.Header["alg"] != alg {
+				t.Errorf("expected alg %s, got %v", alg, parsed.Header["alg"])
+			}
+
+			if parsed.Header["kid"] != key.ID || key.ID == "" {
+				t.Errorf("expected kid %q, got %v", key.ID, parsed.Header["kid"])
+			}
+		})
+	}
+
+	t.Run("should reject an HS256 token keyed with the RSA public key", func(t *testing.T) {
+		key := keys["RS256"]
+		useSigningKey(t, key)
+
+		pub, err := x509.MarshalPKIXPublicKey(key.verifyKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		forged := NewHMACKey(key.ID, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+		token, err := signJWT(forged, newClaims(1, "jti", time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := validateJWT(token); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("should reject a token with an unknown kid", func(t *testing.T) {
+		useSigningKey(t, keys["EdDSA"])
+
+		other := newEdDSAKey(t, "other")
+		token, err := signJWT(other, newClaims(1, "jti", time.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := validateJWT(token); err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+}
+
+func TestJWKS(t *testing.T) {
+	fetch := func(t *testing.T) JWKS {
+		req, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		HandleJWKS(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var jwks JWKS
+		if err := json.NewDecoder(rr.Body).Decode(&jwks); err != nil {
+			t.Fatal(err)
+		}
+
+		return jwks
+	}
+
+	t.Run("should publish a key that verifies our tokens", func(t *testing.T) {
+		key := newRSAKey(t, "rsa-1")
+		useSigningKey(t, key)
+
+		jwks := fetch(t)
+		if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "rsa-1" {
+			t.Fatalf("expected a single key with kid rsa-1, got %+v", jwks.Keys)
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+		token, err := CreateJWT(key, 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return pub, nil })
+		if err != nil {
+			t.Errorf("expected the published key to verify the token, got %v", err)
+		}
+	})
+
+	t.Run("should never publish an HMAC secret", func(t *testing.T) {
+		useSigningKey(t, NewHMACKey("", []byte("secret")))
+
+		if jwks := fetch(t); len(jwks.Keys) != 0 {
+			t.Errorf("expected no keys, got %+v", jwks.Keys)
+		}
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	t.Run("should keep accepting tokens of a retired key until it is dropped", func(t *testing.T) {
+		old := newRSAKey(t, "old")
+		useKeyRing(t, NewKeyRing(old))
+
+		token, err := CreateJWT(Keys.Current(), 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		current := newEdDSAKey(t, "new")
+		useKeyRing(t, NewKeyRing(current, old))
+
+		if _, err := validateJWT(token); err != nil {
+			t.Errorf("expected the retired key to still verify, got %v", err)
+		}
+
+		fresh, err := CreateJWT(Keys.Current(), 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := validateJWT(fresh)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Header["kid"] != "new" {
+			t.Errorf("expected new tokens to be signed with kid new, got %v", parsed.Header["kid"])
+		}
+
+		useKeyRing(t, NewKeyRing(current))
+
+		if _, err := validateJWT(token); err == nil {
+			t.Error("expected tokens of a dropped key to be rejected")
+		}
+	})
+
+	t.Run("should load retired keys from config", func(t *testing.T) {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dir := t.TempDir()
+		pubPath := filepath.Join(dir, "old.pub.pem")
+		if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		secretPath := filepath.Join(dir, "legacy.secret")
+		if err := os.WriteFile(secretPath, []byte("legacy-secret\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		kr, err := loadKeyRing(Config{
+			JWTSigningMethod:  "EdDSA",
+			JWTPrivateKeyFile: writePrivateKeyPEM(t, ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))),
+			JWTKeyID:          "current",
+			JWTTrustedKeys:    "old:RS256:" + pubPath + ", legacy:HS256:" + secretPath,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		useKeyRing(t, kr)
+
+		old := &SigningKey{ID: "old", Method: jwt.SigningMethodRS256, signKey: priv}
+		legacy := NewHMACKey("legacy", []byte("legacy-secret"))
+
+		for _, key := range []*SigningKey{old, legacy} {
+			token, err := signJWT(key, newClaims(1, "jti", time.Now()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := validateJWT(token); err != nil {
+				t.Errorf("expected a token signed by %s to validate, got %v", key.ID, err)
+			}
+		}
+
+		if kids := kr.JWKS().Keys; len(kids) != 2 || kids[0].Kid != "current" || kids[1].Kid != "old" {
+			t.Errorf("expected the current and old public keys to be published, got %+v", kids)
+		}
+	})
+}
@@ -1,30 +1,85 @@
-package main
-
-import (
-	"log"
-
-	"github.com/go-sql-driver/mysql"
-)
-
-func main() {
-	cfg := mysql.Config{
-		User:                 Envs.DBUser,
-		Passwd:               Envs.DBPassword,
-		Addr:                 Envs.DBAddress,
-		DBName:               Envs.DBName,
-		Net:                  "tcp",
-		AllowNativePasswords: true,
-		ParseTime:            true,
-	}
-
-	sqlStorage := NewMySQLStorage(cfg)
-	db, err := sqlStorage.Init()
-	if err != nil {
-		log.Fatalf("Error initializing MySQL storage: %v", err)
-	}
-
-	store := NewStore(db)
-
-	api := NewAPIServer(":3000", store)
-	api.Run()
-}
+package main
+
+import (
+	"database/sql"
+	"log"
+	"net/url"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// SQLStorage is a database the Storage queries run on.
+type SQLStorage interface {
+	Init() (*sql.DB, error)
+	Migrator() (*Migrator, error)
+}
+
+func main() {
+	migrate := len(os.Args) > 1 && os.Args[1] == "migrate"
+
+	var sqlStorage SQLStorage
+	dialect := MySQLDialect
+	switch Envs.StoreDriver {
+	case "mysql":
+		cfg := mysql.Config{
+			User:                 Envs.DBUser,
+			Passwd:               Envs.DBPassword,
+			Addr:                 Envs.DBAddress,
+			DBName:               Envs.DBName,
+			Net:                  "tcp",
+			AllowNativePasswords: true,
+			ParseTime:            true,
+		}
+
+		sqlStorage = NewMySQLStorage(cfg)
+	case "postgres":
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(Envs.DBUser, Envs.DBPassword),
+			Host:     Envs.DBAddress,
+			Path:     Envs.DBName,
+			RawQuery: url.Values{"sslmode": {Envs.DBSSLMode}}.Encode(),
+		}
+
+		sqlStorage = NewPostgresStorage(dsn.String())
+		dialect = PostgresDialect
+	case "sqlite":
+		sqlStorage = NewSQLiteStorage(Envs.SQLitePath)
+		dialect = SQLiteDialect
+	case "memory":
+		if migrate {
+			log.Fatal("The memory store has no schema to migrate")
+		}
+
+		log.Println("Using the in-memory store, all data is lost on exit")
+		api := NewAPIServer(":3000", NewMemoryStore())
+		api.Run()
+		return
+	default:
+		log.Fatalf("Unknown STORE_DRIVER %q, expected mysql, postgres, sqlite or memory", Envs.StoreDriver)
+	}
+
+	// go run . migrate up|down [steps]|status
+	if migrate {
+		m, err := sqlStorage.Migrator()
+		if err == nil {
+			err = runMigrate(m, os.Args[2:], os.Stdout)
+		}
+		if err != nil {
+			log.Fatalf("Error running migrations: %v", err)
+		}
+		return
+	}
+
+	db, err := sqlStorage.Init()
+	if err != nil {
+		log.Fatalf("Error initializing %s storage: %v", Envs.StoreDriver, err)
+	}
+
+	store := NewStoreWithDialect(db, dialect)
+	store.QueryTimeout = Envs.DBQueryTimeout
+
+	api := NewAPIServer(":3000", store)
+	api.Run()
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+var errLastOwner = &StoreError{Kind: ErrConflict, Message: "a project needs at least one owner"}
+
+// errOwnerRemoval is what removeProjectMember answers when the caller may
+// not remove the owner they asked to remove.
+var errOwnerRemoval = errors.New("only owners can remove owners")
+var errUserNotOrgMember = errors.New("user is not a member of the organization")
+
+func (s *ProjectService) HandleProjectMembersList(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectRead); !ok {
+		return
+	}
+
+	members, err := s.store.ListProjectMembers(r.Context(), orgID, projectID)
+	if err != nil {
+		WriteStoreError(w, r, err, "project member")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, members)
+}
+
+func (s *ProjectService) HandleProjectMemberAdd(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload AddProjectMemberPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateProjectMemberPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	caller, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectMembersManage)
+	if !ok {
+		return
+	}
+
+	// maintainers manage the team, only owners hand out ownership
+	if payload.Role == ProjectRoleOwner && !s.isOwner(r.Context(), caller, orgID, projectID) {
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+		return
+	}
+
+	// projects are staffed from their organization only
+	if _, err := s.store.GetOrganizationMember(r.Context(), orgID, payload.UserID); errors.Is(err, ErrNotFound) {
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidReference, errUserNotOrgMember.Error())
+		return
+	} else if err != nil {
+		WriteStoreError(w, r, err, "member")
+		return
+	}
+
+	if _, err := s.store.GetProjectMember(r.Context(), orgID, projectID, payload.UserID); err == nil {
+		WriteProblem(w, r, http.StatusConflict, CodeConflict, "user is already a member")
+		return
+	}
+
+	m, err := s.store.AddProjectMember(r.Context(), orgID, &ProjectMember{
+		ProjectID: projectID,
+		UserID:    payload.UserID,
+		Role:      payload.Role,
+	})
+	if errors.Is(err, ErrNotFound) {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "project member")
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, m)
+}
+
+func (s *ProjectService) HandleProjectMemberRemove(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := strconv.ParseInt(r.PathValue("user_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "invalid user id")
+		return
+	}
+
+	caller, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectMembersManage)
+	if !ok {
+		return
+	}
+
+	err = removeProjectMember(r.Context(), s.store, orgID, projectID, userID, s.isOwner(r.Context(), caller, orgID, projectID))
+	if errors.Is(err, errOwnerRemoval) {
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+		return
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "project member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeProjectMember removes user userID from the project, an owner only
+// if byOwner and the project has another one. The project stays locked from
+// counting the owners to the removal, so owners removing each other at the
+// same time can't leave it without any.
+func removeProjectMember(ctx context.Context, store Store, orgID, projectID, userID int64, byOwner bool) error {
+	return store.WithTx(ctx, func(tx Store) error {
+		if err := tx.LockProject(ctx, orgID, projectID); err != nil {
+			return err
+		}
+
+		members, err := tx.ListProjectMembers(ctx, orgID, projectID)
+		if err != nil {
+			return err
+		}
+
+		var target *ProjectMember
+		owners := 0
+		for _, m := range members {
+			if m.UserID == userID {
+				target = m
+			}
+			if m.Role == ProjectRoleOwner {
+				owners++
+			}
+		}
+
+		if target == nil {
+			return ErrNotFound
+		}
+
+		if target.Role == ProjectRoleOwner {
+			if !byOwner {
+				return errOwnerRemoval
+			}
+
+			if owners == 1 {
+				return errLastOwner
+			}
+		}
+
+		_, err = tx.RemoveProjectMember(ctx, orgID, projectID, userID)
+		return err
+	})
+}
+
+func (s *ProjectService) isOwner(ctx context.Context, u *User, orgID, projectID int64) bool {
+	if u.Role == RoleAdmin {
+		return true
+	}
+
+	m, err := s.store.GetProjectMember(ctx, orgID, projectID, u.ID)
+	return err == nil && m.Role == ProjectRoleOwner
+}
+
+// authorizeProject checks the authenticated user may do p in the project of
+// organization orgID and writes the error response when they may not. Admins
+// may do anything in any project of the organization, everyone else needs a
+// membership granting p.
+func authorizeProject(w http.ResponseWriter, r *http.Request, store Store, orgID, projectID int64, p ProjectPermission) (*User, bool) {
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return nil, false
+	}
+
+	if u.Role == RoleAdmin {
+		return u, true
+	}
+
+	m, err := store.GetProjectMember(r.Context(), orgID, projectID, u.ID)
+	if errors.Is(err, ErrNotFound) {
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+		return nil, false
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "project member")
+		return nil, false
+	}
+
+	if !m.Role.Can(p) {
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+		return nil, false
+	}
+
+	return u, true
+}
+
+func projectIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("project_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "invalid project id")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func validateProjectMemberPayload(m *AddProjectMemberPayload) error {
+	var v Validator
+	v.ID("user_id", m.UserID).Required()
+	v.Check("role", m.Role.Valid(), "role must be one of owner, maintainer, contributor or viewer")
+	return v.Err()
+}
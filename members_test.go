@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestProjectMembers(t *testing.T) {
+	newStore := func() *MockStore {
+		return &MockStore{
+			users: []*User{
+				{ID: 1, Role: RoleMember},
+				{ID: 2, Role: RoleMember},
+				{ID: 3, Role: RoleMember},
+				{ID: 4, Role: RoleMember},
+			},
+			projects: []*Project{{ID: 1, OrgID: testOrgID}},
+			orgMembers: []*OrganizationMember{
+				{OrgID: testOrgID, UserID: 1, Role: OrgRoleOwner},
+				{OrgID: testOrgID, UserID: 2, Role: OrgRoleMember},
+				{OrgID: testOrgID, UserID: 3, Role: OrgRoleMember},
+				{OrgID: testOrgID, UserID: 4, Role: OrgRoleMember},
+			},
+			members: []*ProjectMember{
+				{ProjectID: 1, UserID: 1, Role: ProjectRoleOwner},
+				{ProjectID: 1, UserID: 2, Role: ProjectRoleMaintainer},
+				{ProjectID: 1, UserID: 3, Role: ProjectRoleContributor},
+			},
+		}
+	}
+
+	routes := func(ms *MockStore) http.Handler {
+		router := http.NewServeMux()
+
+		service := NewProjectService(ms)
+		router.HandleFunc("GET /projects/{project_id}/members", service.HandleProjectMembersList)
+		router.HandleFunc("POST /projects/{project_id}/members", service.HandleProjectMemberAdd)
+		router.HandleFunc("DELETE /projects/{project_id}/members/{user_id}", service.HandleProjectMemberRemove)
+
+		return router
+	}
+
+	t.Run("should list members to members", func(t *testing.T) {
+		rr := serveAs(t, routes(newStore()), 3, http.MethodGet, "/projects/1/members", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var members []ProjectMember
+		if err := json.NewDecoder(rr.Body).Decode(&members); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(members) != 3 {
+			t.Errorf("expected 3 members, got %d", len(members))
+		}
+	})
+
+	t.Run("should not list members to outsiders", func(t *testing.T) {
+		if rr := serveAs(t, routes(newStore()), 4, http.MethodGet, "/projects/1/members", nil); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		caller  int64
+		payload *AddProjectMemberPayload
+		want    int
+	}{
+		{
+			name:    "should let a maintainer add a contributor",
+			caller:  2,
+			payload: &AddProjectMemberPayload{UserID: 4, Role: ProjectRoleContributor},
+			want:    http.StatusCreated,
+		},
+		{
+			name:    "should let an owner add an owner",
+			caller:  1,
+			payload: &AddProjectMemberPayload{UserID: 4, Role: ProjectRoleOwner},
+			want:    http.StatusCreated,
+		},
+		{
+			name:    "should forbid a maintainer from adding an owner",
+			caller:  2,
+			payload: &AddProjectMemberPayload{UserID: 4, Role: ProjectRoleOwner},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "should forbid a contributor from adding members",
+			caller:  3,
+			payload: &AddProjectMemberPayload{UserID: 4, Role: ProjectRoleViewer},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "should reject an existing member",
+			caller:  1,
+			payload: &AddProjectMemberPayload{UserID: 3, Role: ProjectRoleViewer},
+			want:    http.StatusConflict,
+		},
+		{
+			name:    "should validate the role",
+			caller:  1,
+			payload: &AddProjectMemberPayload{UserID: 4, Role: "boss"},
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "should reject users outside the organization",
+			caller:  1,
+			payload: &AddProjectMemberPayload{UserID: 5, Role: ProjectRoleViewer},
+			want:    http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serveAs(t, routes(newStore()), tt.caller, http.MethodPost, "/projects/1/members", tt.payload)
+			if rr.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+
+	t.Run("should map store errors of the member list", func(t *testing.T) {
+		ms := newStore()
+		ms.errs = map[string]error{"ListProjectMembers": ErrNotFound}
+
+		if rr := serveAs(t, routes(ms), 3, http.MethodGet, "/projects/1/members", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("should not blame the caller for a failing lookup", func(t *testing.T) {
+		ms := newStore()
+		ms.errs = map[string]error{"GetOrganizationMember": errors.New("connection refused")}
+
+		rr := serveAs(t, routes(ms), 1, http.MethodPost, "/projects/1/members", &AddProjectMemberPayload{UserID: 4, Role: ProjectRoleViewer})
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+	})
+
+	t.Run("should remove a member", func(t *testing.T) {
+		ms := newStore()
+
+		if rr := serveAs(t, routes(ms), 2, http.MethodDelete, "/projects/1/members/3", nil); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if len(ms.members) != 2 {
+			t.Errorf("expected 2 members left, got %d", len(ms.members))
+		}
+	})
+
+	t.Run("should forbid a maintainer from removing an owner", func(t *testing.T) {
+		if rr := serveAs(t, routes(newStore()), 2, http.MethodDelete, "/projects/1/members/1", nil); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should keep the last owner", func(t *testing.T) {
+		if rr := serveAs(t, routes(newStore()), 1, http.MethodDelete, "/projects/1/members/1", nil); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d", http.StatusConflict, rr.Code)
+		}
+	})
+
+	t.Run("should return not found for non-members", func(t *testing.T) {
+		if rr := serveAs(t, routes(newStore()), 1, http.MethodDelete, "/projects/1/members/4", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
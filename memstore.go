@@ -0,0 +1,994 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping everything in maps, for local development
+// and tests without a database. It enforces what the SQL schema does: unique
+// emails and token hashes, foreign keys, cascading deletes and auto
+// incremented ids. It hands out copies, so callers can't change stored rows
+// behind its back.
+type MemoryStore struct {
+	mu sync.Mutex
+	memoryTables
+}
+
+type memoryTables struct {
+	lastID map[string]int64
+
+	users      map[int64]*User
+	orgs       map[int64]*Organization
+	orgMembers map[[2]int64]*OrganizationMember
+	projects   map[int64]*Project
+	tasks      map[int64]*Task
+	members    map[[2]int64]*ProjectMember
+	statuses   map[int64]*ProjectStatus
+	// the transitions of a project are replaced as a whole, never changed
+	workflows     map[int64][]WorkflowTransition
+	refreshTokens map[int64]*RefreshToken
+	revoked       map[string]revokedToken
+}
+
+type revokedToken struct {
+	userID    int64
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		memoryTables: memoryTables{
+			lastID:        make(map[string]int64),
+			users:         make(map[int64]*User),
+			orgs:          make(map[int64]*Organization),
+			orgMembers:    make(map[[2]int64]*OrganizationMember),
+			projects:      make(map[int64]*Project),
+			tasks:         make(map[int64]*Task),
+			members:       make(map[[2]int64]*ProjectMember),
+			statuses:      make(map[int64]*ProjectStatus),
+			workflows:     make(map[int64][]WorkflowTransition),
+			refreshTokens: make(map[int64]*RefreshToken),
+			revoked:       make(map[string]revokedToken),
+		},
+	}
+}
+
+// WithTx implements Store. fn runs on a copy of the tables, which replaces
+// them when fn succeeds. The store stays locked meanwhile, so transactions
+// are serializable and other callers wait for them.
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &MemoryStore{memoryTables: s.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.memoryTables = tx.memoryTables
+	return nil
+}
+
+// clone copies the tables and their rows, rows are updated in place.
+func (t *memoryTables) clone() memoryTables {
+	return memoryTables{
+		lastID:        maps.Clone(t.lastID),
+		users:         cloneRows(t.users),
+		orgs:          cloneRows(t.orgs),
+		orgMembers:    cloneRows(t.orgMembers),
+		projects:      cloneRows(t.projects),
+		tasks:         cloneRows(t.tasks),
+		members:       cloneRows(t.members),
+		statuses:      cloneRows(t.statuses),
+		workflows:     maps.Clone(t.workflows),
+		refreshTokens: cloneRows(t.refreshTokens),
+		revoked:       maps.Clone(t.revoked),
+	}
+}
+
+func cloneRows[K comparable, V any](rows map[K]*V) map[K]*V {
+	c := make(map[K]*V, len(rows))
+	for k, row := range rows {
+		copied := *row
+		c[k] = &copied
+	}
+
+	return c
+}
+
+func (s *MemoryStore) nextID(table string) int64 {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// userExists checks an optional user reference, nil references are fine.
+func (s *MemoryStore) userExists(id *int64) bool {
+	if id == nil {
+		return true
+	}
+
+	_, ok := s.users[*id]
+	return ok
+}
+
+func (s *MemoryStore) projectInOrg(orgID, projectID int64) bool {
+	p, ok := s.projects[projectID]
+	return ok && p.OrgID == orgID
+}
+
+// projectStatuses are the stored statuses of a project, ordered by position
+// and then id like Storage.ListProjectStatuses orders them.
+func (s *MemoryStore) projectStatuses(projectID int64) []*ProjectStatus {
+	statuses := []*ProjectStatus{}
+	for _, st := range s.statuses {
+		if st.ProjectID == projectID {
+			statuses = append(statuses, st)
+		}
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Position != statuses[j].Position {
+			return statuses[i].Position < statuses[j].Position
+		}
+		return statuses[i].ID < statuses[j].ID
+	})
+	return statuses
+}
+
+func (s *MemoryStore) statusInProject(projectID, statusID int64) bool {
+	st, ok := s.statuses[statusID]
+	return ok && st.ProjectID == projectID
+}
+
+// withStatus copies t with the name and category of its status, which the
+// SQL queries join.
+func (s *MemoryStore) withStatus(t *Task) *Task {
+	found := *t
+	found.Status = s.statuses[t.StatusID].Name
+	found.StatusCategory = s.statuses[t.StatusID].Category
+	return &found
+}
+
+func (s *MemoryStore) CreateUser(ctx context.Context, u *User) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Email == u.Email {
+			return nil, ErrConflict
+		}
+	}
+
+	u.ID = s.nextID("users")
+	u.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	stored := *u
+	s.users[u.ID] = &stored
+	return u, nil
+}
+
+func (s *MemoryStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// like the SQL query, never hand out the password hash by id
+	found := *u
+	found.Password = ""
+	return &found, nil
+}
+
+func (s *MemoryStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			found := *u
+			found.TokensValidAfter = nil
+			return &found, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) UpdateUserRole(ctx context.Context, id string, role Role) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return 0, nil
+	}
+
+	u, ok := s.users[userID]
+	if !ok {
+		return 0, nil
+	}
+
+	u.Role = role
+	return 1, nil
+}
+
+func (s *MemoryStore) CreateOrganization(ctx context.Context, o *Organization) (*Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userExists(o.CreatedBy) {
+		return nil, ErrForeignKey
+	}
+
+	o.ID = s.nextID("organizations")
+	o.CreatedAt = time.Now()
+
+	stored := *o
+	s.orgs[o.ID] = &stored
+	return o, nil
+}
+
+func (s *MemoryStore) ListUserOrganizations(ctx context.Context, userID int64) ([]*Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	orgs := []*Organization{}
+	for _, o := range s.orgs {
+		if _, ok := s.orgMembers[[2]int64{o.ID, userID}]; ok {
+			found := *o
+			orgs = append(orgs, &found)
+		}
+	}
+
+	sort.Slice(orgs, func(i, j int) bool { return orgs[i].ID < orgs[j].ID })
+	return orgs, nil
+}
+
+func (s *MemoryStore) AddOrganizationMember(ctx context.Context, m *OrganizationMember) (*OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[m.OrgID]; !ok || !s.userExists(&m.UserID) {
+		return nil, ErrForeignKey
+	}
+
+	key := [2]int64{m.OrgID, m.UserID}
+	if _, ok := s.orgMembers[key]; ok {
+		return nil, ErrConflict
+	}
+
+	m.CreatedAt = time.Now()
+
+	stored := *m
+	s.orgMembers[key] = &stored
+	return m, nil
+}
+
+func (s *MemoryStore) GetOrganizationMember(ctx context.Context, orgID, userID int64) (*OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.orgMembers[[2]int64{orgID, userID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	found := *m
+	return &found, nil
+}
+
+func (s *MemoryStore) ListOrganizationMembers(ctx context.Context, orgID int64) ([]*OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := []*OrganizationMember{}
+	for _, m := range s.orgMembers {
+		if m.OrgID == orgID {
+			found := *m
+			members = append(members, &found)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(members[j].CreatedAt)
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *MemoryStore) CreateTask(ctx context.Context, orgID int64, t *Task) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, t.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	if !s.userExists(&t.AssignedTo) || !s.userExists(t.CreatedBy) {
+		return nil, ErrForeignKey
+	}
+
+	statuses := s.projectStatuses(t.ProjectID)
+	if len(statuses) == 0 {
+		return nil, ErrNotFound
+	}
+
+	t.ID = s.nextID("tasks")
+	t.StatusID = statuses[0].ID
+	t.CreatedAt = time.Now()
+
+	stored := *t
+	s.tasks[t.ID] = &stored
+	return s.withStatus(t), nil
+}
+
+func (s *MemoryStore) GetTask(ctx context.Context, orgID int64, id string) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taskID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	t, ok := s.tasks[taskID]
+	if !ok || !s.projectInOrg(orgID, t.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	return s.withStatus(t), nil
+}
+
+func (s *MemoryStore) UpdateTask(ctx context.Context, orgID int64, id string, u TaskUpdate) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taskID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	t, ok := s.tasks[taskID]
+	if !ok || !s.projectInOrg(orgID, t.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	projectID, statusID := t.ProjectID, t.StatusID
+	if u.ProjectID != nil {
+		projectID = *u.ProjectID
+	}
+
+	if u.StatusID != nil {
+		statusID = *u.StatusID
+	}
+
+	if !s.projectInOrg(orgID, projectID) || !s.statusInProject(projectID, statusID) || !s.userExists(u.AssignedTo) {
+		return nil, ErrForeignKey
+	}
+
+	if u.StatusID != nil && u.FromStatusID != nil && *u.StatusID != *u.FromStatusID && t.StatusID != *u.FromStatusID {
+		return nil, errTaskStatusChanged
+	}
+
+	updated := *t
+	if u.Name != nil {
+		updated.Name = *u.Name
+	}
+
+	if u.StatusID != nil {
+		updated.StatusID = *u.StatusID
+	}
+
+	if u.AssignedTo != nil {
+		updated.AssignedTo = *u.AssignedTo
+	}
+
+	if u.ProjectID != nil {
+		updated.ProjectID = *u.ProjectID
+	}
+
+	s.tasks[taskID] = &updated
+	return s.withStatus(&updated), nil
+}
+
+func (s *MemoryStore) ListTasks(ctx context.Context, orgID int64, q TaskQuery) (*TaskPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks := []*Task{}
+	for _, t := range s.tasks {
+		if !s.projectInOrg(orgID, t.ProjectID) {
+			continue
+		}
+
+		if _, ok := s.members[[2]int64{t.ProjectID, q.MemberID}]; q.MemberID != 0 && !ok {
+			continue
+		}
+
+		tasks = append(tasks, s.withStatus(t))
+	}
+
+	return pageTasks(tasks, q), nil
+}
+
+// pageTasks applies the filters of q but MemberID, its order and page to
+// tasks, as the queries of Storage.ListTasks do.
+func pageTasks(tasks []*Task, q TaskQuery) *TaskPage {
+	matches := []*Task{}
+	for _, t := range tasks {
+		switch {
+		case q.ProjectID != 0 && t.ProjectID != q.ProjectID,
+			q.AssignedTo != 0 && t.AssignedTo != q.AssignedTo,
+			len(q.Statuses) > 0 && !slices.Contains(q.Statuses, t.Status),
+			len(q.Categories) > 0 && !slices.Contains(q.Categories, t.StatusCategory),
+			!q.CreatedAfter.IsZero() && t.CreatedAt.Before(q.CreatedAfter),
+			!q.CreatedBefore.IsZero() && !t.CreatedAt.Before(q.CreatedBefore):
+			continue
+		}
+
+		matches = append(matches, t)
+	}
+
+	before := func(a, b *Task) bool {
+		if q.Desc {
+			a, b = b, a
+		}
+
+		switch {
+		case q.Sort == TaskSortName && a.Name != b.Name:
+			return a.Name < b.Name
+		case q.Sort != TaskSortName && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return before(matches[i], matches[j]) })
+
+	page := &TaskPage{Tasks: []*Task{}}
+	if q.CountTotal {
+		page.Total = int64(len(matches))
+	}
+
+	for _, t := range matches {
+		if q.After != nil && !before(q.After, t) {
+			continue
+		}
+
+		if len(page.Tasks) == q.Limit {
+			page.More = true
+			break
+		}
+		page.Tasks = append(page.Tasks, t)
+	}
+
+	return page
+}
+
+func (s *MemoryStore) CreateRefreshToken(ctx context.Context, rt *RefreshToken) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userExists(&rt.UserID) {
+		return nil, ErrForeignKey
+	}
+
+	for _, existing := range s.refreshTokens {
+		if existing.TokenHash == rt.TokenHash {
+			return nil, ErrConflict
+		}
+	}
+
+	rt.ID = s.nextID("refresh_tokens")
+	rt.CreatedAt = time.Now()
+
+	stored := *rt
+	s.refreshTokens[rt.ID] = &stored
+	return rt, nil
+}
+
+func (s *MemoryStore) GetRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rt := range s.refreshTokens {
+		if rt.TokenHash == hash {
+			found := *rt
+			return &found, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (s *MemoryStore) UseRefreshToken(ctx context.Context, id int64, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rt, ok := s.refreshTokens[id]
+	if !ok || rt.UsedAt != nil || rt.RevokedAt != nil {
+		return false, nil
+	}
+
+	rt.UsedAt = &at
+	return true, nil
+}
+
+func (s *MemoryStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, rt := range s.refreshTokens {
+		if rt.FamilyID == familyID && rt.RevokedAt == nil {
+			rt.RevokedAt = &at
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userExists(&userID) {
+		return ErrForeignKey
+	}
+
+	if _, ok := s.revoked[jti]; ok {
+		return ErrConflict
+	}
+
+	s.revoked[jti] = revokedToken{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *MemoryStore) RevokeUserTokens(ctx context.Context, userID int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.TokensValidAfter = &at
+	}
+
+	for _, rt := range s.refreshTokens {
+		if rt.UserID == userID && rt.RevokedAt == nil {
+			rt.RevokedAt = &at
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for jti, rt := range s.revoked {
+		if rt.expiresAt.Before(before) {
+			delete(s.revoked, jti)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (s *MemoryStore) CreateProject(ctx context.Context, orgID int64, p *Project) (*Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orgs[orgID]; !ok || !s.userExists(p.CreatedBy) {
+		return nil, ErrForeignKey
+	}
+
+	p.ID = s.nextID("projects")
+	p.OrgID = orgID
+	p.CreatedAt = time.Now()
+
+	stored := *p
+	s.projects[p.ID] = &stored
+
+	for _, st := range defaultStatuses {
+		st.ID = s.nextID("project_statuses")
+		st.ProjectID = p.ID
+		st.CreatedAt = p.CreatedAt
+		s.statuses[st.ID] = &st
+	}
+
+	return p, nil
+}
+
+func (s *MemoryStore) GetProjectByID(ctx context.Context, orgID int64, id string) (*Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	projectID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || !s.projectInOrg(orgID, projectID) {
+		return nil, ErrNotFound
+	}
+
+	found := *s.projects[projectID]
+	return &found, nil
+}
+
+func (s *MemoryStore) ListProjects(ctx context.Context, orgID int64, q ProjectQuery) (*ProjectPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	projects := []*Project{}
+	for _, p := range s.projects {
+		if p.OrgID != orgID {
+			continue
+		}
+
+		if _, ok := s.members[[2]int64{p.ID, q.MemberID}]; q.MemberID != 0 && !ok {
+			continue
+		}
+
+		found := *p
+		projects = append(projects, &found)
+	}
+
+	return pageProjects(projects, q), nil
+}
+
+// pageProjects applies the name filters, order and page of q to projects, as
+// the queries of Storage.ListProjects do.
+func pageProjects(projects []*Project, q ProjectQuery) *ProjectPage {
+	matches := []*Project{}
+	for _, p := range projects {
+		name := strings.ToLower(p.Name)
+		if strings.HasPrefix(name, strings.ToLower(q.NamePrefix)) && strings.Contains(name, strings.ToLower(q.Search)) {
+			matches = append(matches, p)
+		}
+	}
+
+	before := func(a, b *Project) bool {
+		if q.Desc {
+			a, b = b, a
+		}
+
+		switch {
+		case q.Sort == ProjectSortName && a.Name != b.Name:
+			return a.Name < b.Name
+		case q.Sort != ProjectSortName && !a.CreatedAt.Equal(b.CreatedAt):
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return before(matches[i], matches[j]) })
+
+	page := &ProjectPage{Projects: []*Project{}}
+	if q.CountTotal {
+		page.Total = int64(len(matches))
+	}
+
+	for _, p := range matches {
+		if q.After != nil && !before(q.After, p) {
+			continue
+		}
+
+		if len(page.Projects) == q.Limit {
+			page.More = true
+			break
+		}
+		page.Projects = append(page.Projects, p)
+	}
+
+	return page
+}
+
+// LockProject implements Store. Transactions hold the whole store already.
+func (s *MemoryStore) LockProject(ctx context.Context, orgID, projectID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, projectID) {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteProject(ctx context.Context, orgID int64, id string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	projectID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || !s.projectInOrg(orgID, projectID) {
+		return 0, nil
+	}
+
+	for id, t := range s.tasks {
+		if t.ProjectID == projectID {
+			delete(s.tasks, id)
+		}
+	}
+
+	for key, m := range s.members {
+		if m.ProjectID == projectID {
+			delete(s.members, key)
+		}
+	}
+
+	for id, st := range s.statuses {
+		if st.ProjectID == projectID {
+			delete(s.statuses, id)
+		}
+	}
+
+	delete(s.workflows, projectID)
+	delete(s.projects, projectID)
+	return 1, nil
+}
+
+func (s *MemoryStore) AddProjectMember(ctx context.Context, orgID int64, m *ProjectMember) (*ProjectMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, m.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	if !s.userExists(&m.UserID) {
+		return nil, ErrForeignKey
+	}
+
+	key := [2]int64{m.ProjectID, m.UserID}
+	if _, ok := s.members[key]; ok {
+		return nil, ErrConflict
+	}
+
+	m.CreatedAt = time.Now()
+
+	stored := *m
+	s.members[key] = &stored
+	return m, nil
+}
+
+func (s *MemoryStore) GetProjectMember(ctx context.Context, orgID, projectID, userID int64) (*ProjectMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m, ok := s.members[[2]int64{projectID, userID}]
+	if !ok || !s.projectInOrg(orgID, projectID) {
+		return nil, ErrNotFound
+	}
+
+	found := *m
+	return &found, nil
+}
+
+func (s *MemoryStore) ListProjectMembers(ctx context.Context, orgID, projectID int64) ([]*ProjectMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := []*ProjectMember{}
+	if !s.projectInOrg(orgID, projectID) {
+		return members, nil
+	}
+
+	for _, m := range s.members {
+		if m.ProjectID == projectID {
+			found := *m
+			members = append(members, &found)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(members[j].CreatedAt)
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members, nil
+}
+
+func (s *MemoryStore) RemoveProjectMember(ctx context.Context, orgID, projectID, userID int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := [2]int64{projectID, userID}
+	if _, ok := s.members[key]; !ok || !s.projectInOrg(orgID, projectID) {
+		return 0, nil
+	}
+
+	delete(s.members, key)
+	return 1, nil
+}
+
+func (s *MemoryStore) CreateProjectStatus(ctx context.Context, orgID int64, st *ProjectStatus) (*ProjectStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, st.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	for _, existing := range s.projectStatuses(st.ProjectID) {
+		if existing.Name == st.Name {
+			return nil, ErrConflict
+		}
+	}
+
+	st.ID = s.nextID("project_statuses")
+	st.CreatedAt = time.Now()
+
+	stored := *st
+	s.statuses[st.ID] = &stored
+	return st, nil
+}
+
+func (s *MemoryStore) GetProjectStatus(ctx context.Context, orgID, projectID, id int64) (*ProjectStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, projectID) || !s.statusInProject(projectID, id) {
+		return nil, ErrNotFound
+	}
+
+	found := *s.statuses[id]
+	return &found, nil
+}
+
+func (s *MemoryStore) ListProjectStatuses(ctx context.Context, orgID, projectID int64) ([]*ProjectStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := []*ProjectStatus{}
+	if !s.projectInOrg(orgID, projectID) {
+		return statuses, nil
+	}
+
+	for _, st := range s.projectStatuses(projectID) {
+		found := *st
+		statuses = append(statuses, &found)
+	}
+
+	return statuses, nil
+}
+
+func (s *MemoryStore) UpdateProjectStatus(ctx context.Context, orgID, projectID, id int64, u ProjectStatusUpdate) (*ProjectStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, projectID) || !s.statusInProject(projectID, id) {
+		return nil, ErrNotFound
+	}
+
+	if u.Category != nil && !u.Category.Valid() {
+		return nil, ErrValidation
+	}
+
+	if u.Name != nil {
+		for _, existing := range s.projectStatuses(projectID) {
+			if existing.ID != id && existing.Name == *u.Name {
+				return nil, ErrConflict
+			}
+		}
+	}
+
+	updated := *s.statuses[id]
+	if u.Name != nil {
+		updated.Name = *u.Name
+	}
+
+	if u.Category != nil {
+		updated.Category = *u.Category
+	}
+
+	if u.Position != nil {
+		updated.Position = *u.Position
+	}
+
+	s.statuses[id] = &updated
+
+	found := updated
+	return &found, nil
+}
+
+func (s *MemoryStore) DeleteProjectStatus(ctx context.Context, orgID, projectID, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, projectID) || !s.statusInProject(projectID, id) {
+		return 0, nil
+	}
+
+	// tasks reference their status without a cascade
+	for _, t := range s.tasks {
+		if t.StatusID == id {
+			return 0, errStatusHasTasks
+		}
+	}
+
+	// transitions do cascade
+	transitions := []WorkflowTransition{}
+	for _, tr := range s.workflows[projectID] {
+		if tr.FromStatusID != id && tr.ToStatusID != id {
+			transitions = append(transitions, tr)
+		}
+	}
+
+	if len(transitions) == 0 {
+		delete(s.workflows, projectID)
+	} else {
+		s.workflows[projectID] = transitions
+	}
+
+	delete(s.statuses, id)
+	return 1, nil
+}
+
+func (s *MemoryStore) ListWorkflowTransitions(ctx context.Context, orgID, projectID int64) ([]WorkflowTransition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, projectID) {
+		return []WorkflowTransition{}, nil
+	}
+
+	return append([]WorkflowTransition{}, s.workflows[projectID]...), nil
+}
+
+func (s *MemoryStore) ReplaceWorkflowTransitions(ctx context.Context, orgID, projectID int64, transitions []WorkflowTransition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.projectInOrg(orgID, projectID) {
+		return ErrNotFound
+	}
+
+	seen := make(map[[2]int64]bool)
+	for _, tr := range transitions {
+		if !tr.Guard.Valid() {
+			return ErrValidation
+		}
+
+		if !s.statusInProject(projectID, tr.FromStatusID) || !s.statusInProject(projectID, tr.ToStatusID) {
+			return ErrForeignKey
+		}
+
+		key := [2]int64{tr.FromStatusID, tr.ToStatusID}
+		if seen[key] {
+			return ErrConflict
+		}
+		seen[key] = true
+	}
+
+	if len(transitions) == 0 {
+		delete(s.workflows, projectID)
+		return nil
+	}
+
+	s.workflows[projectID] = slices.Clone(transitions)
+	return nil
+}
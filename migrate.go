@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+var errMigrationLocked = errors.New("another migrator holds the migration lock")
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+const migrationLockName = "projectmanager.schema_migrations"
+
+// MigrationDialect is what differs between databases when migrating: where
+// their migrations live, how schema_migrations is created and how concurrent
+// migrators are kept apart.
+type MigrationDialect struct {
+	Dir                  string
+	CreateMigrationTable string
+	// SQL rewrites the placeholders of the queries on schema_migrations.
+	SQL SQLDialect
+	// Lock blocks until conn holds the migration lock, the returned function
+	// releases it, failed tells whether the migration run failed.
+	Lock func(conn *sql.Conn, timeout time.Duration) (release func(failed bool), err error)
+}
+
+var MySQLMigrations = MigrationDialect{
+	Dir: "migrations/mysql",
+	CreateMigrationTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT UNSIGNED NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+			PRIMARY KEY (version)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+	`,
+	Lock: mysqlMigrationLock,
+}
+
+var SQLiteMigrations = MigrationDialect{
+	Dir: "migrations/sqlite",
+	CreateMigrationTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	Lock: sqliteMigrationLock,
+}
+
+var PostgresMigrations = MigrationDialect{
+	Dir: "migrations/postgres",
+	CreateMigrationTable: `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+	`,
+	SQL:  PostgresDialect,
+	Lock: postgresMigrationLock,
+}
+
+// Migration is one step of the schema, read from a pair of
+// NNNN_name.up.sql / NNNN_name.down.sql files.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus is a known migration and whether it has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	// the migration was applied from a different up script than this build has
+	Modified bool
+}
+
+// Migrator applies the embedded migrations in version order, recording each
+// one in schema_migrations. MySQL can't roll back DDL, so a migration that
+// fails halfway leaves its earlier statements applied and has to be cleaned
+// up by hand before running it again. SQLite and Postgres run everything in
+// one transaction and roll back on failure.
+type Migrator struct {
+	db          *sql.DB
+	dialect     MigrationDialect
+	migrations  []Migration
+	LockTimeout time.Duration
+}
+
+func NewMigrator(db *sql.DB, dialect MigrationDialect) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, dialect.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:          db,
+		dialect:     dialect,
+		migrations:  migrations,
+		LockTimeout: 30 * time.Second,
+	}, nil
+}
+
+// Up applies every pending migration and returns how many it applied.
+func (m *Migrator) Up() (int, error) {
+	n := 0
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := m.verify(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			if err := execScript(conn, mig.Up); err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			_, err := conn.ExecContext(context.Background(), m.dialect.SQL.rebind("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+				mig.Version, mig.Name, mig.Checksum, time.Now())
+			if err != nil {
+				return err
+			}
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Down reverts the last steps applied migrations and returns how many it
+// reverted.
+func (m *Migrator) Down(steps int) (int, error) {
+	n := 0
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := m.verify(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && n < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			if err := execScript(conn, mig.Down); err != nil {
+				return fmt.Errorf("reverting migration %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			if _, err := conn.ExecContext(context.Background(), m.dialect.SQL.rebind("DELETE FROM schema_migrations WHERE version = ?"), mig.Version); err != nil {
+				return err
+			}
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Status lists every known migration, applied or not.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(func(conn *sql.Conn) error {
+		applied, err := m.applied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			s := MigrationStatus{Migration: mig}
+			if a, ok := applied[mig.Version]; ok {
+				s.AppliedAt = &a.appliedAt
+				s.Modified = a.checksum != mig.Checksum
+			}
+			statuses = append(statuses, s)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) applied(conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		var version int64
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+
+	return applied, rows.Err()
+}
+
+// verify refuses to touch a database whose history doesn't match this
+// build: an applied migration that was edited afterwards, or one we don't
+// know, means the schema isn't what the code expects.
+func (m *Migrator) verify(conn *sql.Conn) (map[int64]appliedMigration, error) {
+	applied, err := m.applied(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+
+	for version, a := range applied {
+		mig, ok := known[version]
+		if !ok {
+			return nil, fmt.Errorf("migration %04d is applied but unknown to this build", version)
+		}
+
+		if a.checksum != mig.Checksum {
+			return nil, fmt.Errorf("migration %04d_%s was modified after it was applied", version, mig.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+// withLock runs fn on a single connection holding the migration lock, so
+// two instances starting at once don't both apply the same migration.
+func (m *Migrator) withLock(fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	release, err := m.dialect.Lock(conn, m.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer func() { release(err != nil) }()
+
+	if _, err := conn.ExecContext(context.Background(), m.dialect.CreateMigrationTable); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// mysqlMigrationLock takes a named lock, which MySQL ties to the connection.
+func mysqlMigrationLock(conn *sql.Conn, timeout time.Duration) (func(bool), error) {
+	ctx := context.Background()
+
+	var got sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, int(timeout.Seconds())).Scan(&got)
+	if err != nil {
+		return nil, err
+	}
+
+	if got.Int64 != 1 {
+		return nil, errMigrationLocked
+	}
+
+	return func(bool) {
+		conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+	}, nil
+}
+
+// sqliteMigrationLock opens a write transaction, which SQLite only grants to
+// one connection at a time. As SQLite's DDL is transactional it also makes
+// the whole run atomic.
+func sqliteMigrationLock(conn *sql.Conn, timeout time.Duration) (func(bool), error) {
+	ctx := context.Background()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA busy_timeout = "+strconv.FormatInt(timeout.Milliseconds(), 10)); err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return nil, err
+	}
+
+	return func(failed bool) {
+		if failed {
+			conn.ExecContext(ctx, "ROLLBACK")
+			return
+		}
+		conn.ExecContext(ctx, "COMMIT")
+	}, nil
+}
+
+// postgresMigrationLock takes an advisory lock for the length of a
+// transaction. Postgres' DDL is transactional as well, so like on SQLite the
+// whole run is atomic.
+func postgresMigrationLock(conn *sql.Conn, timeout time.Duration) (func(bool), error) {
+	ctx := context.Background()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		return nil, err
+	}
+
+	// lock_timeout bounds the wait for advisory locks too
+	_, err := conn.ExecContext(ctx, "SET LOCAL lock_timeout = "+strconv.FormatInt(timeout.Milliseconds(), 10))
+	if err == nil {
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", migrationLockName)
+	}
+	if err != nil {
+		conn.ExecContext(ctx, "ROLLBACK")
+
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "55P03" {
+			return nil, errMigrationLocked
+		}
+		return nil, err
+	}
+
+	return func(failed bool) {
+		if failed {
+			conn.ExecContext(ctx, "ROLLBACK")
+			return
+		}
+		conn.ExecContext(ctx, "COMMIT")
+	}, nil
+}
+
+// execScript runs the statements of a migration one by one, the driver
+// doesn't accept several statements in a single Exec.
+func execScript(conn *sql.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(context.Background(), stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitStatements splits a script on the semicolons ending a line and drops
+// "--" comment lines. That's all our migrations need; a semicolon inside a
+// string at the end of a line would split too early.
+func splitStatements(script string) []string {
+	var stmts []string
+	var b strings.Builder
+
+	flush := func() {
+		if stmt := strings.TrimSpace(b.String()); stmt != "" {
+			stmts = append(stmts, strings.TrimSuffix(stmt, ";"))
+		}
+		b.Reset()
+	}
+
+	for _, line := range strings.Split(normalizeNewlines(script), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		b.WriteString(line)
+		b.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+	flush()
+
+	return stmts
+}
+
+// loadMigrations reads the migration pairs in dir of fsys, ordered by version.
+func loadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := migrationFileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected file %s in migrations, expected NNNN_name.up.sql or NNNN_name.down.sql", e.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, mig.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", mig.Version, mig.Name)
+		}
+
+		mig.Checksum = migrationChecksum(mig.Up)
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrationChecksum hashes the up script, ignoring line endings so a
+// checkout with CRLF endings doesn't look like an edited migration.
+func migrationChecksum(up string) string {
+	sum := sha256.Sum256([]byte(normalizeNewlines(up)))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// runMigrate implements the "migrate up|down [steps]|status" subcommand.
+func runMigrate(m *Migrator, args []string, out io.Writer) error {
+	var err error
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := m.Up()
+		fmt.Fprintf(out, "applied %d migrations\n", n)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+
+		n, err := m.Down(steps)
+		fmt.Fprintf(out, "reverted %d migrations\n", n)
+		return err
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+		for _, s := range statuses {
+			status := "pending"
+			if s.AppliedAt != nil {
+				status = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Modified {
+				status += " (modified)"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, status)
+		}
+
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
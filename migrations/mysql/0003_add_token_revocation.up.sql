@@ -0,0 +1,12 @@
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP NULL DEFAULT NULL AFTER `password`;
+
+CREATE TABLE revoked_tokens (
+	jti CHAR(32) NOT NULL,
+	user_id INT UNSIGNED NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (jti),
+	KEY (expires_at),
+	FOREIGN KEY (user_id) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
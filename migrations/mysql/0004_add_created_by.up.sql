@@ -0,0 +1,7 @@
+ALTER TABLE projects
+	ADD COLUMN created_by INT UNSIGNED NULL AFTER `name`,
+	ADD CONSTRAINT fk_projects_created_by FOREIGN KEY (created_by) REFERENCES users(id);
+
+ALTER TABLE tasks
+	ADD COLUMN created_by INT UNSIGNED NULL AFTER `assigned_to`,
+	ADD CONSTRAINT fk_tasks_created_by FOREIGN KEY (created_by) REFERENCES users(id);
@@ -0,0 +1,15 @@
+CREATE TABLE project_members (
+	project_id INT UNSIGNED NOT NULL,
+	user_id INT UNSIGNED NOT NULL,
+	role ENUM('owner', 'maintainer', 'contributor', 'viewer') NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (project_id, user_id),
+	KEY (user_id),
+	FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- projects created before memberships existed are owned by their creator
+INSERT INTO project_members (project_id, user_id, role)
+	SELECT id, created_by, 'owner' FROM projects WHERE created_by IS NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE projects DROP FOREIGN KEY fk_projects_org_id, DROP COLUMN org_id;
+
+DROP TABLE organization_members;
+DROP TABLE organizations;
@@ -0,0 +1,39 @@
+CREATE TABLE organizations (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	name VARCHAR(255) NOT NULL,
+	created_by INT UNSIGNED NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (id),
+	FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE organization_members (
+	org_id INT UNSIGNED NOT NULL,
+	user_id INT UNSIGNED NOT NULL,
+	role ENUM('owner', 'member') NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (org_id, user_id),
+	KEY (user_id),
+	FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+-- everything created before organizations existed was shared by everyone,
+-- so it moves into a single organization everyone belongs to
+INSERT INTO organizations (name)
+	SELECT 'Default' FROM DUAL
+	WHERE EXISTS (SELECT 1 FROM users) OR EXISTS (SELECT 1 FROM projects);
+
+INSERT INTO organization_members (org_id, user_id, role)
+	SELECT o.id, u.id, IF(u.role = 'admin', 'owner', 'member') FROM users u CROSS JOIN organizations o;
+
+ALTER TABLE projects ADD COLUMN org_id INT UNSIGNED NULL AFTER `id`;
+
+UPDATE projects SET org_id = (SELECT MIN(id) FROM organizations);
+
+ALTER TABLE projects
+	MODIFY org_id INT UNSIGNED NOT NULL,
+	ADD KEY (org_id),
+	ADD CONSTRAINT fk_projects_org_id FOREIGN KEY (org_id) REFERENCES organizations(id);
@@ -0,0 +1,10 @@
+-- MySQL drops the indexes it made for the foreign keys once these cover
+-- them, the foreign keys need indexes of their own back first
+ALTER TABLE tasks
+	ADD KEY tasks_project_id (project_id),
+	ADD KEY tasks_assigned_to (assigned_to);
+
+ALTER TABLE tasks
+	DROP KEY tasks_project_id_created_at,
+	DROP KEY tasks_project_id_status,
+	DROP KEY tasks_assigned_to_status;
@@ -0,0 +1,5 @@
+-- for listing the tasks of a project and of an assignee, see Store.ListTasks
+ALTER TABLE tasks
+	ADD KEY tasks_project_id_created_at (project_id, created_at),
+	ADD KEY tasks_project_id_status (project_id, status),
+	ADD KEY tasks_assigned_to_status (assigned_to, status);
@@ -0,0 +1,11 @@
+-- the status changes a project allows its tasks, see Workflow. Projects
+-- without any follow DefaultWorkflow.
+CREATE TABLE workflow_transitions (
+	project_id INT UNSIGNED NOT NULL,
+	from_status ENUM('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') NOT NULL,
+	to_status ENUM('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') NOT NULL,
+	guard ENUM('assignee', 'maintainer') NULL DEFAULT NULL,
+
+	PRIMARY KEY (project_id, from_status, to_status),
+	FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,45 @@
+-- statuses the enum doesn't have become TODO, IN_PROGRESS or DONE by their
+-- category, transitions that end up repeated or going nowhere are dropped
+CREATE TABLE workflow_transitions (
+	project_id INT UNSIGNED NOT NULL,
+	from_status ENUM('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') NOT NULL,
+	to_status ENUM('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') NOT NULL,
+	guard ENUM('assignee', 'maintainer') NULL DEFAULT NULL,
+
+	PRIMARY KEY (project_id, from_status, to_status),
+	FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT IGNORE INTO workflow_transitions (project_id, from_status, to_status, guard)
+	SELECT project_id, from_status, to_status, guard FROM (
+		SELECT f.project_id,
+			CASE WHEN f.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN f.name
+				WHEN f.category = 'todo' THEN 'TODO' WHEN f.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END AS from_status,
+			CASE WHEN t.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN t.name
+				WHEN t.category = 'todo' THEN 'TODO' WHEN t.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END AS to_status,
+			st.guard
+		FROM status_transitions st
+		JOIN project_statuses f ON f.id = st.from_status_id
+		JOIN project_statuses t ON t.id = st.to_status_id
+	) mapped
+	WHERE from_status <> to_status;
+
+DROP TABLE status_transitions;
+
+ALTER TABLE tasks ADD COLUMN status ENUM('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') NOT NULL DEFAULT 'TODO' AFTER `name`;
+
+UPDATE tasks t JOIN project_statuses s ON s.id = t.status_id
+	SET t.status = CASE WHEN s.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN s.name
+		WHEN s.category = 'todo' THEN 'TODO' WHEN s.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END;
+
+ALTER TABLE tasks
+	ADD KEY tasks_project_id_status (project_id, status),
+	ADD KEY tasks_assigned_to_status (assigned_to, status);
+
+ALTER TABLE tasks
+	DROP FOREIGN KEY fk_tasks_status_id,
+	DROP KEY tasks_project_id_status_id,
+	DROP KEY tasks_assigned_to_status_id,
+	DROP COLUMN status_id;
+
+DROP TABLE project_statuses;
@@ -0,0 +1,55 @@
+-- statuses belong to a project now, ordered by position and grouped into
+-- categories. Every project starts with the four statuses tasks had.
+CREATE TABLE project_statuses (
+	id INT UNSIGNED NOT NULL AUTO_INCREMENT,
+	project_id INT UNSIGNED NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	category ENUM('todo', 'in_progress', 'done') NOT NULL,
+	position INT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (id),
+	UNIQUE KEY project_statuses_project_id_name (project_id, name),
+	FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO project_statuses (project_id, name, category, position)
+	SELECT id, 'TODO', 'todo', 0 FROM projects
+	UNION ALL SELECT id, 'IN_PROGRESS', 'in_progress', 1 FROM projects
+	UNION ALL SELECT id, 'IN_TESTING', 'in_progress', 2 FROM projects
+	UNION ALL SELECT id, 'DONE', 'done', 3 FROM projects;
+
+ALTER TABLE tasks ADD COLUMN status_id INT UNSIGNED NULL AFTER `status`;
+
+UPDATE tasks t JOIN project_statuses s ON s.project_id = t.project_id AND s.name = CAST(t.status AS CHAR)
+	SET t.status_id = s.id;
+
+ALTER TABLE tasks
+	MODIFY status_id INT UNSIGNED NOT NULL,
+	ADD KEY tasks_project_id_status_id (project_id, status_id),
+	ADD KEY tasks_assigned_to_status_id (assigned_to, status_id),
+	ADD CONSTRAINT fk_tasks_status_id FOREIGN KEY (status_id) REFERENCES project_statuses(id);
+
+ALTER TABLE tasks
+	DROP KEY tasks_project_id_status,
+	DROP KEY tasks_assigned_to_status,
+	DROP COLUMN status;
+
+-- transitions go between statuses, which know their project
+CREATE TABLE status_transitions (
+	from_status_id INT UNSIGNED NOT NULL,
+	to_status_id INT UNSIGNED NOT NULL,
+	guard ENUM('assignee', 'maintainer') NULL DEFAULT NULL,
+
+	PRIMARY KEY (from_status_id, to_status_id),
+	KEY (to_status_id),
+	FOREIGN KEY (from_status_id) REFERENCES project_statuses(id) ON DELETE CASCADE,
+	FOREIGN KEY (to_status_id) REFERENCES project_statuses(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO status_transitions (from_status_id, to_status_id, guard)
+	SELECT f.id, t.id, wt.guard FROM workflow_transitions wt
+	JOIN project_statuses f ON f.project_id = wt.project_id AND f.name = CAST(wt.from_status AS CHAR)
+	JOIN project_statuses t ON t.project_id = wt.project_id AND t.name = CAST(wt.to_status AS CHAR);
+
+DROP TABLE workflow_transitions;
@@ -0,0 +1 @@
+ALTER TABLE users MODIFY tokens_valid_after TIMESTAMP NULL DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE users MODIFY tokens_valid_after TIMESTAMP(6) NULL DEFAULT NULL;
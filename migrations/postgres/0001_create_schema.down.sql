@@ -0,0 +1,13 @@
+DROP TABLE revoked_tokens;
+DROP TABLE refresh_tokens;
+DROP TABLE project_members;
+DROP TABLE tasks;
+DROP TABLE projects;
+DROP TABLE organization_members;
+DROP TABLE organizations;
+DROP TABLE users;
+
+DROP TYPE task_status;
+DROP TYPE project_role;
+DROP TYPE org_role;
+DROP TYPE user_role;
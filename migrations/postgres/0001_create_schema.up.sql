@@ -0,0 +1,90 @@
+-- Postgres databases start from the current schema, like SQLite ones. Task
+-- status and the roles are native enums.
+CREATE TYPE user_role AS ENUM ('admin', 'member', 'viewer');
+CREATE TYPE org_role AS ENUM ('owner', 'member');
+CREATE TYPE project_role AS ENUM ('owner', 'maintainer', 'contributor', 'viewer');
+CREATE TYPE task_status AS ENUM ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE');
+
+CREATE TABLE users (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	email VARCHAR(255) NOT NULL UNIQUE,
+	first_name VARCHAR(255) NOT NULL,
+	last_name VARCHAR(255) NOT NULL,
+	password VARCHAR(255) NOT NULL,
+	role user_role NOT NULL DEFAULT 'member',
+	tokens_valid_after TIMESTAMPTZ NULL DEFAULT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organizations (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	created_by BIGINT NULL REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE organization_members (
+	org_id BIGINT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role org_role NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id ON organization_members (user_id);
+
+CREATE TABLE projects (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	org_id BIGINT NOT NULL REFERENCES organizations(id),
+	name VARCHAR(255) NOT NULL,
+	created_by BIGINT NULL REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX projects_org_id ON projects (org_id);
+
+CREATE TABLE tasks (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	status task_status NOT NULL DEFAULT 'TODO',
+	project_id BIGINT NOT NULL REFERENCES projects(id),
+	assigned_to BIGINT NOT NULL REFERENCES users(id),
+	created_by BIGINT NULL REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX tasks_project_id ON tasks (project_id);
+
+CREATE TABLE project_members (
+	project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role project_role NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX project_members_user_id ON project_members (user_id);
+
+CREATE TABLE refresh_tokens (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	family_id CHAR(32) NOT NULL,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ NULL DEFAULT NULL,
+	revoked_at TIMESTAMPTZ NULL DEFAULT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_family_id ON refresh_tokens (family_id);
+
+CREATE TABLE revoked_tokens (
+	jti CHAR(32) NOT NULL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users(id),
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX revoked_tokens_expires_at ON revoked_tokens (expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX tasks_assigned_to_status;
+DROP INDEX tasks_project_id_status;
+DROP INDEX tasks_project_id_created_at;
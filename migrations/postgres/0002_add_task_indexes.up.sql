@@ -0,0 +1,4 @@
+-- for listing the tasks of a project and of an assignee, see Store.ListTasks
+CREATE INDEX tasks_project_id_created_at ON tasks (project_id, created_at);
+CREATE INDEX tasks_project_id_status ON tasks (project_id, status);
+CREATE INDEX tasks_assigned_to_status ON tasks (assigned_to, status);
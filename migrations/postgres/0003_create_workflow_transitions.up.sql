@@ -0,0 +1,12 @@
+-- the status changes a project allows its tasks, see Workflow. Projects
+-- without any follow DefaultWorkflow.
+CREATE TYPE transition_guard AS ENUM ('assignee', 'maintainer');
+
+CREATE TABLE workflow_transitions (
+	project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	from_status task_status NOT NULL,
+	to_status task_status NOT NULL,
+	guard transition_guard NULL DEFAULT NULL,
+
+	PRIMARY KEY (project_id, from_status, to_status)
+);
@@ -0,0 +1,46 @@
+-- statuses the enum doesn't have become TODO, IN_PROGRESS or DONE by their
+-- category, transitions that end up repeated or going nowhere are dropped
+CREATE TYPE task_status AS ENUM ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE');
+
+CREATE TABLE workflow_transitions (
+	project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	from_status task_status NOT NULL,
+	to_status task_status NOT NULL,
+	guard transition_guard NULL DEFAULT NULL,
+
+	PRIMARY KEY (project_id, from_status, to_status)
+);
+
+INSERT INTO workflow_transitions (project_id, from_status, to_status, guard)
+	SELECT project_id, from_status::task_status, to_status::task_status, guard FROM (
+		SELECT f.project_id,
+			CASE WHEN f.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN f.name
+				WHEN f.category = 'todo' THEN 'TODO' WHEN f.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END AS from_status,
+			CASE WHEN t.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN t.name
+				WHEN t.category = 'todo' THEN 'TODO' WHEN t.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END AS to_status,
+			st.guard
+		FROM status_transitions st
+		JOIN project_statuses f ON f.id = st.from_status_id
+		JOIN project_statuses t ON t.id = st.to_status_id
+	) mapped
+	WHERE from_status <> to_status
+	ON CONFLICT DO NOTHING;
+
+DROP TABLE status_transitions;
+
+ALTER TABLE tasks ADD COLUMN status task_status NOT NULL DEFAULT 'TODO';
+
+UPDATE tasks t SET status = (CASE WHEN s.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN s.name
+		WHEN s.category = 'todo' THEN 'TODO' WHEN s.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END)::task_status
+	FROM project_statuses s WHERE s.id = t.status_id;
+
+CREATE INDEX tasks_project_id_status ON tasks (project_id, status);
+CREATE INDEX tasks_assigned_to_status ON tasks (assigned_to, status);
+
+DROP INDEX tasks_project_id_status_id;
+DROP INDEX tasks_assigned_to_status_id;
+
+ALTER TABLE tasks DROP COLUMN status_id;
+
+DROP TABLE project_statuses;
+DROP TYPE status_category;
@@ -0,0 +1,54 @@
+-- statuses belong to a project now, ordered by position and grouped into
+-- categories. Every project starts with the four statuses tasks had.
+CREATE TYPE status_category AS ENUM ('todo', 'in_progress', 'done');
+
+CREATE TABLE project_statuses (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	project_id BIGINT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	name VARCHAR(64) NOT NULL,
+	category status_category NOT NULL,
+	position INTEGER NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	UNIQUE (project_id, name)
+);
+
+INSERT INTO project_statuses (project_id, name, category, position)
+	SELECT id, 'TODO', 'todo', 0 FROM projects
+	UNION ALL SELECT id, 'IN_PROGRESS', 'in_progress', 1 FROM projects
+	UNION ALL SELECT id, 'IN_TESTING', 'in_progress', 2 FROM projects
+	UNION ALL SELECT id, 'DONE', 'done', 3 FROM projects;
+
+ALTER TABLE tasks ADD COLUMN status_id BIGINT NULL REFERENCES project_statuses(id);
+
+UPDATE tasks t SET status_id = s.id
+	FROM project_statuses s WHERE s.project_id = t.project_id AND s.name = t.status::text;
+
+ALTER TABLE tasks ALTER COLUMN status_id SET NOT NULL;
+
+CREATE INDEX tasks_project_id_status_id ON tasks (project_id, status_id);
+CREATE INDEX tasks_assigned_to_status_id ON tasks (assigned_to, status_id);
+
+DROP INDEX tasks_project_id_status;
+DROP INDEX tasks_assigned_to_status;
+
+ALTER TABLE tasks DROP COLUMN status;
+
+-- transitions go between statuses, which know their project
+CREATE TABLE status_transitions (
+	from_status_id BIGINT NOT NULL REFERENCES project_statuses(id) ON DELETE CASCADE,
+	to_status_id BIGINT NOT NULL REFERENCES project_statuses(id) ON DELETE CASCADE,
+	guard transition_guard NULL DEFAULT NULL,
+
+	PRIMARY KEY (from_status_id, to_status_id)
+);
+
+CREATE INDEX status_transitions_to_status_id ON status_transitions (to_status_id);
+
+INSERT INTO status_transitions (from_status_id, to_status_id, guard)
+	SELECT f.id, t.id, wt.guard FROM workflow_transitions wt
+	JOIN project_statuses f ON f.project_id = wt.project_id AND f.name = wt.from_status::text
+	JOIN project_statuses t ON t.project_id = wt.project_id AND t.name = wt.to_status::text;
+
+DROP TABLE workflow_transitions;
+DROP TYPE task_status;
@@ -0,0 +1,8 @@
+DROP TABLE revoked_tokens;
+DROP TABLE refresh_tokens;
+DROP TABLE project_members;
+DROP TABLE tasks;
+DROP TABLE projects;
+DROP TABLE organization_members;
+DROP TABLE organizations;
+DROP TABLE users;
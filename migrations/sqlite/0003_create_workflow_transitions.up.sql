@@ -0,0 +1,10 @@
+-- the status changes a project allows its tasks, see Workflow. Projects
+-- without any follow DefaultWorkflow.
+CREATE TABLE workflow_transitions (
+	project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	from_status VARCHAR(16) NOT NULL CHECK (from_status IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE')),
+	to_status VARCHAR(16) NOT NULL CHECK (to_status IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE')),
+	guard VARCHAR(16) NULL DEFAULT NULL CHECK (guard IN ('assignee', 'maintainer')),
+
+	PRIMARY KEY (project_id, from_status, to_status)
+);
@@ -0,0 +1,53 @@
+-- statuses the enum doesn't have become TODO, IN_PROGRESS or DONE by their
+-- category, transitions that end up repeated or going nowhere are dropped
+CREATE TABLE workflow_transitions (
+	project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	from_status VARCHAR(16) NOT NULL CHECK (from_status IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE')),
+	to_status VARCHAR(16) NOT NULL CHECK (to_status IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE')),
+	guard VARCHAR(16) NULL DEFAULT NULL CHECK (guard IN ('assignee', 'maintainer')),
+
+	PRIMARY KEY (project_id, from_status, to_status)
+);
+
+INSERT OR IGNORE INTO workflow_transitions (project_id, from_status, to_status, guard)
+	SELECT project_id, from_status, to_status, guard FROM (
+		SELECT f.project_id,
+			CASE WHEN f.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN f.name
+				WHEN f.category = 'todo' THEN 'TODO' WHEN f.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END AS from_status,
+			CASE WHEN t.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN t.name
+				WHEN t.category = 'todo' THEN 'TODO' WHEN t.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END AS to_status,
+			st.guard
+		FROM status_transitions st
+		JOIN project_statuses f ON f.id = st.from_status_id
+		JOIN project_statuses t ON t.id = st.to_status_id
+	) mapped
+	WHERE from_status <> to_status;
+
+DROP TABLE status_transitions;
+
+CREATE TABLE tasks_with_status (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'TODO' CHECK (status IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE')),
+	project_id INTEGER NOT NULL REFERENCES projects(id),
+	assigned_to INTEGER NOT NULL REFERENCES users(id),
+	created_by INTEGER NULL REFERENCES users(id),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tasks_with_status (id, name, status, project_id, assigned_to, created_by, created_at)
+	SELECT t.id, t.name,
+		CASE WHEN s.name IN ('TODO', 'IN_PROGRESS', 'IN_TESTING', 'DONE') THEN s.name
+			WHEN s.category = 'todo' THEN 'TODO' WHEN s.category = 'done' THEN 'DONE' ELSE 'IN_PROGRESS' END,
+		t.project_id, t.assigned_to, t.created_by, t.created_at
+	FROM tasks t JOIN project_statuses s ON s.id = t.status_id;
+
+DROP TABLE tasks;
+
+ALTER TABLE tasks_with_status RENAME TO tasks;
+
+CREATE INDEX tasks_project_id_created_at ON tasks (project_id, created_at);
+CREATE INDEX tasks_project_id_status ON tasks (project_id, status);
+CREATE INDEX tasks_assigned_to_status ON tasks (assigned_to, status);
+
+DROP TABLE project_statuses;
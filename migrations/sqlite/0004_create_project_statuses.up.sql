@@ -0,0 +1,60 @@
+-- statuses belong to a project now, ordered by position and grouped into
+-- categories. Every project starts with the four statuses tasks had.
+CREATE TABLE project_statuses (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+	name VARCHAR(64) NOT NULL,
+	category VARCHAR(16) NOT NULL CHECK (category IN ('todo', 'in_progress', 'done')),
+	position INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	UNIQUE (project_id, name)
+);
+
+INSERT INTO project_statuses (project_id, name, category, position)
+	SELECT id, 'TODO', 'todo', 0 FROM projects
+	UNION ALL SELECT id, 'IN_PROGRESS', 'in_progress', 1 FROM projects
+	UNION ALL SELECT id, 'IN_TESTING', 'in_progress', 2 FROM projects
+	UNION ALL SELECT id, 'DONE', 'done', 3 FROM projects;
+
+-- SQLite can't make an added column NOT NULL, so tasks are copied into a
+-- new table instead
+CREATE TABLE tasks_with_status_id (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	status_id INTEGER NOT NULL REFERENCES project_statuses(id),
+	project_id INTEGER NOT NULL REFERENCES projects(id),
+	assigned_to INTEGER NOT NULL REFERENCES users(id),
+	created_by INTEGER NULL REFERENCES users(id),
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tasks_with_status_id (id, name, status_id, project_id, assigned_to, created_by, created_at)
+	SELECT t.id, t.name, s.id, t.project_id, t.assigned_to, t.created_by, t.created_at
+	FROM tasks t JOIN project_statuses s ON s.project_id = t.project_id AND s.name = t.status;
+
+DROP TABLE tasks;
+
+ALTER TABLE tasks_with_status_id RENAME TO tasks;
+
+CREATE INDEX tasks_project_id_created_at ON tasks (project_id, created_at);
+CREATE INDEX tasks_project_id_status_id ON tasks (project_id, status_id);
+CREATE INDEX tasks_assigned_to_status_id ON tasks (assigned_to, status_id);
+
+-- transitions go between statuses, which know their project
+CREATE TABLE status_transitions (
+	from_status_id INTEGER NOT NULL REFERENCES project_statuses(id) ON DELETE CASCADE,
+	to_status_id INTEGER NOT NULL REFERENCES project_statuses(id) ON DELETE CASCADE,
+	guard VARCHAR(16) NULL DEFAULT NULL CHECK (guard IN ('assignee', 'maintainer')),
+
+	PRIMARY KEY (from_status_id, to_status_id)
+);
+
+CREATE INDEX status_transitions_to_status_id ON status_transitions (to_status_id);
+
+INSERT INTO status_transitions (from_status_id, to_status_id, guard)
+	SELECT f.id, t.id, wt.guard FROM workflow_transitions wt
+	JOIN project_statuses f ON f.project_id = wt.project_id AND f.name = wt.from_status
+	JOIN project_statuses t ON t.project_id = wt.project_id AND t.name = wt.to_status;
+
+DROP TABLE workflow_transitions;
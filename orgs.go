@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+type OrganizationService struct {
+	store Store
+}
+
+func NewOrganizationService(s Store) *OrganizationService {
+	return &OrganizationService{
+		store: s,
+	}
+}
+
+func (s *OrganizationService) RegisterRoutes(r *http.ServeMux) {
+	r.HandleFunc("POST /orgs", WithJWTAuth(s.HandleOrganizationCreate, s.store))
+	r.HandleFunc("GET /orgs", WithJWTAuth(s.HandleOrganizationsList, s.store))
+
+	// members...
+	r.HandleFunc("GET /orgs/{org_id}/members", WithJWTAuth(s.HandleOrganizationMembersList, s.store))
+	r.HandleFunc("POST /orgs/{org_id}/members", WithJWTAuth(s.HandleOrganizationMemberAdd, s.store))
+}
+
+func (s *OrganizationService) HandleOrganizationCreate(w http.ResponseWriter, r *http.Request) {
+	var payload CreateOrganizationPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateOrganizationPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	o, err := createOrganization(r.Context(), s.store, payload.Name, u.ID)
+	if err != nil {
+		WriteStoreError(w, r, err, "organization")
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, o)
+}
+
+func (s *OrganizationService) HandleOrganizationsList(w http.ResponseWriter, r *http.Request) {
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	orgs, err := s.store.ListUserOrganizations(r.Context(), u.ID)
+	if err != nil {
+		WriteStoreError(w, r, err, "organization")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, orgs)
+}
+
+func (s *OrganizationService) HandleOrganizationMembersList(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := s.authorizeOrg(w, r, false)
+	if !ok {
+		return
+	}
+
+	members, err := s.store.ListOrganizationMembers(r.Context(), orgID)
+	if err != nil {
+		WriteStoreError(w, r, err, "organization member")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, members)
+}
+
+func (s *OrganizationService) HandleOrganizationMemberAdd(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := s.authorizeOrg(w, r, true)
+	if !ok {
+		return
+	}
+
+	var payload AddOrganizationMemberPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateOrganizationMemberPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	if _, err := s.store.GetUserByID(r.Context(), strconv.FormatInt(payload.UserID, 10)); errors.Is(err, ErrNotFound) {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "user does not exist")
+		return
+	} else if err != nil {
+		WriteStoreError(w, r, err, "user")
+		return
+	}
+
+	if _, err := s.store.GetOrganizationMember(r.Context(), orgID, payload.UserID); err == nil {
+		WriteProblem(w, r, http.StatusConflict, CodeConflict, "user is already a member")
+		return
+	}
+
+	m, err := s.store.AddOrganizationMember(r.Context(), &OrganizationMember{
+		OrgID:  orgID,
+		UserID: payload.UserID,
+		Role:   payload.Role,
+	})
+	if err != nil {
+		WriteStoreError(w, r, err, "organization member")
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, m)
+}
+
+// authorizeOrg checks the caller belongs to the organization in the path,
+// and owns it when ownerOnly is set. Outsiders get a 404 so organization ids
+// can't be probed.
+func (s *OrganizationService) authorizeOrg(w http.ResponseWriter, r *http.Request, ownerOnly bool) (int64, bool) {
+	orgID, err := strconv.ParseInt(r.PathValue("org_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "invalid organization id")
+		return 0, false
+	}
+
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return 0, false
+	}
+
+	m, err := s.store.GetOrganizationMember(r.Context(), orgID, u.ID)
+	if errors.Is(err, ErrNotFound) {
+		WriteProblem(w, r, http.StatusNotFound, CodeNotFound, "organization not found")
+		return 0, false
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "organization member")
+		return 0, false
+	}
+
+	if ownerOnly && m.Role != OrgRoleOwner {
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+		return 0, false
+	}
+
+	return orgID, true
+}
+
+// createOrganization creates an organization owned by userID, in one
+// transaction so there's no organization without an owner.
+func createOrganization(ctx context.Context, store Store, name string, userID int64) (*Organization, error) {
+	var o *Organization
+	err := store.WithTx(ctx, func(tx Store) error {
+		var err error
+		o, err = tx.CreateOrganization(ctx, &Organization{Name: name, CreatedBy: &userID})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.AddOrganizationMember(ctx, &OrganizationMember{OrgID: o.ID, UserID: userID, Role: OrgRoleOwner})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// orgFromRequest returns the active organization of the request, everything
+// tenant scoped needs one.
+func orgFromRequest(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	orgID, ok := OrgFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "no active organization, set the "+orgHeader+" header")
+		return 0, false
+	}
+
+	return orgID, true
+}
+
+func validateOrganizationPayload(payload *CreateOrganizationPayload) error {
+	var v Validator
+	v.String("name", payload.Name).Required().MaxLen(maxNameLength)
+	return v.Err()
+}
+
+func validateOrganizationMemberPayload(payload *AddOrganizationMemberPayload) error {
+	var v Validator
+	v.ID("user_id", payload.UserID).Required()
+	v.Check("role", payload.Role.Valid(), "role must be one of owner or member")
+	return v.Err()
+}
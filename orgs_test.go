@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOrganizations(t *testing.T) {
+	ctx := context.Background()
+
+	newStore := func() *MockStore {
+		return &MockStore{
+			users: []*User{
+				{ID: 1, Role: RoleMember},
+				{ID: 2, Role: RoleMember},
+				{ID: 3, Role: RoleMember},
+			},
+			orgs: []*Organization{{ID: 1, Name: "acme"}},
+			orgMembers: []*OrganizationMember{
+				{OrgID: 1, UserID: 1, Role: OrgRoleOwner},
+				{OrgID: 1, UserID: 2, Role: OrgRoleMember},
+			},
+		}
+	}
+
+	serve := func(t *testing.T, ms *MockStore, caller int64, method, path string, payload any) *httptest.ResponseRecorder {
+		token, err := CreateJWT(Keys.Current(), caller, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// through WithJWTAuth, which picks the organization
+		req := newJSONRequest(t, method, path, payload)
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+
+		NewOrganizationService(ms).RegisterRoutes(router)
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should make the creator the owner", func(t *testing.T) {
+		ms := newStore()
+
+		rr := serve(t, ms, 3, http.MethodPost, "/orgs", &CreateOrganizationPayload{Name: "umbrella"})
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		var o Organization
+		if err := json.NewDecoder(rr.Body).Decode(&o); err != nil {
+			t.Fatal(err)
+		}
+
+		if m, err := ms.GetOrganizationMember(ctx, o.ID, 3); err != nil || m.Role != OrgRoleOwner {
+			t.Errorf("expected user 3 to own organization %d, got %+v, %v", o.ID, m, err)
+		}
+	})
+
+	t.Run("should only list the caller's organizations", func(t *testing.T) {
+		rr := serve(t, newStore(), 3, http.MethodGet, "/orgs", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var orgs []Organization
+		if err := json.NewDecoder(rr.Body).Decode(&orgs); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(orgs) != 0 {
+			t.Errorf("expected no organizations, got %+v", orgs)
+		}
+	})
+
+	t.Run("should hide organizations from outsiders", func(t *testing.T) {
+		if rr := serve(t, newStore(), 3, http.MethodGet, "/orgs/1/members", nil); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		caller  int64
+		payload *AddOrganizationMemberPayload
+		want    int
+	}{
+		{
+			name:    "should let an owner add a member",
+			caller:  1,
+			payload: &AddOrganizationMemberPayload{UserID: 3, Role: OrgRoleMember},
+			want:    http.StatusCreated,
+		},
+		{
+			name:    "should forbid members from adding members",
+			caller:  2,
+			payload: &AddOrganizationMemberPayload{UserID: 3, Role: OrgRoleMember},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:    "should reject an existing member",
+			caller:  1,
+			payload: &AddOrganizationMemberPayload{UserID: 2, Role: OrgRoleMember},
+			want:    http.StatusConflict,
+		},
+		{
+			name:    "should validate the role",
+			caller:  1,
+			payload: &AddOrganizationMemberPayload{UserID: 3, Role: "boss"},
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "should reject unknown users",
+			caller:  1,
+			payload: &AddOrganizationMemberPayload{UserID: 9, Role: OrgRoleMember},
+			want:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(t, newStore(), tt.caller, http.MethodPost, "/orgs/1/members", tt.payload)
+			if rr.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestActiveOrganization(t *testing.T) {
+	newStore := func() *MockStore {
+		return &MockStore{
+			users: []*User{{ID: 1, Role: RoleMember}},
+			orgMembers: []*OrganizationMember{
+				{OrgID: 1, UserID: 1, Role: OrgRoleOwner},
+			},
+		}
+	}
+
+	serve := func(t *testing.T, claimOrg int64, header string) (int64, int) {
+		token, err := CreateJWT(Keys.Current(), 1, claimOrg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", token)
+		if header != "" {
+			req.Header.Set(orgHeader, header)
+		}
+
+		var active int64
+		handler := WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+			active, _ = OrgFromContext(r.Context())
+		}, newStore())
+
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return active, rr.Code
+	}
+
+	t.Run("should use the organization of the token", func(t *testing.T) {
+		if active, code := serve(t, 1, ""); code != http.StatusOK || active != 1 {
+			t.Errorf("expected organization 1, got %d (status %d)", active, code)
+		}
+	})
+
+	t.Run("should let the header pick the organization", func(t *testing.T) {
+		if active, code := serve(t, 0, "1"); code != http.StatusOK || active != 1 {
+			t.Errorf("expected organization 1, got %d (status %d)", active, code)
+		}
+	})
+
+	t.Run("should forbid organizations the user doesn't belong to", func(t *testing.T) {
+		if _, code := serve(t, 1, "2"); code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, code)
+		}
+
+		if _, code := serve(t, 2, ""); code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, code)
+		}
+	})
+
+	t.Run("should reject a malformed header", func(t *testing.T) {
+		if _, code := serve(t, 1, "acme"); code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+func TestOrganizationIsolation(t *testing.T) {
+	ctx := context.Background()
+
+	// user 1 is an admin of organization 1, project 2 and task 20 belong to
+	// organization 2
+	ms := &MockStore{
+		projects: []*Project{{ID: 1, OrgID: 1}, {ID: 2, OrgID: 2}},
+		members: []*ProjectMember{
+			{ProjectID: 1, UserID: 1, Role: ProjectRoleOwner},
+			{ProjectID: 2, UserID: 2, Role: ProjectRoleOwner},
+		},
+		tasks: []*Task{
+			{ID: 10, ProjectID: 1, AssignedTo: 1},
+			{ID: 20, ProjectID: 2, AssignedTo: 2},
+		},
+	}
+	admin := &User{ID: 1, Role: RoleAdmin}
+
+	serve := func(t *testing.T, method, path string, payload any) int {
+		req := authenticateIn(newJSONRequest(t, method, path, payload), admin, 1)
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+
+		projects := NewProjectService(ms)
+		router.HandleFunc("GET /projects/{project_id}", projects.HandleProjectGet)
+		router.HandleFunc("DELETE /projects/{project_id}", projects.HandleProjectDelete)
+		router.HandleFunc("POST /projects/{project_id}/members", projects.HandleProjectMemberAdd)
+
+		tasks := NewTasksService(ms)
+		router.HandleFunc("POST /tasks", tasks.HandleCreateTask)
+		router.HandleFunc("GET /tasks/{task_id}", tasks.HandleGetTask)
+
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("should not find projects of other organizations", func(t *testing.T) {
+		if code := serve(t, http.MethodGet, "/projects/2", nil); code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("should not find tasks of other organizations", func(t *testing.T) {
+		if code := serve(t, http.MethodGet, "/tasks/20", nil); code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("should not create tasks in projects of other organizations", func(t *testing.T) {
+		code := serve(t, http.MethodPost, "/tasks", &CreateTaskPayload{Name: "task", ProjectID: 2, AssignedTo: 2})
+		if code == http.StatusCreated {
+			t.Errorf("expected the task to be rejected, got status code %d", code)
+		}
+	})
+
+	t.Run("should not delete projects of other organizations", func(t *testing.T) {
+		serve(t, http.MethodDelete, "/projects/2", nil)
+
+		if _, err := ms.GetProjectByID(ctx, 2, "2"); err != nil {
+			t.Errorf("expected project 2 to survive, got %v", err)
+		}
+	})
+
+	t.Run("should not add members to projects of other organizations", func(t *testing.T) {
+		code := serve(t, http.MethodPost, "/projects/2/members", &AddProjectMemberPayload{UserID: 1, Role: ProjectRoleOwner})
+		if code == http.StatusCreated {
+			t.Errorf("expected the member to be rejected, got status code %d", code)
+		}
+	})
+}
+
+func TestRegisterCreatesOrganization(t *testing.T) {
+	ms := &MockStore{}
+
+	b, err := json.Marshal(&RegisterUserPayload{FirstName: "Ada", LastName: "Lovelace", Email: "ada@gmail.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "/users/register", bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	router := http.NewServeMux()
+
+	router.HandleFunc("/users/register", NewUserService(ms).HandleUserRegister)
+
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+	}
+
+	if len(ms.orgs) != 1 || len(ms.orgMembers) != 1 || ms.orgMembers[0].Role != OrgRoleOwner {
+		t.Fatalf("expected a personal organization owned by the user, got %+v", ms.orgMembers)
+	}
+
+	var tokens TokenResponse
+	if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := validateJWT(tokens.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if orgID := token.Claims.(*Claims).OrgID; orgID != ms.orgs[0].ID {
+		t.Errorf("expected the token to work in organization %d, got %d", ms.orgs[0].ID, orgID)
+	}
+}
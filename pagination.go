@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// PageResponse is a page of a collection. NextCursor fetches the page after
+// it and is left out on the last page. Total counts the matches across all
+// pages and is only set when asked for with include_total=true.
+type PageResponse[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// PageParams are the query parameters every paginated collection takes:
+// limit, cursor, sort and include_total.
+type PageParams struct {
+	Limit int
+	// Sort is the column to sort by, Desc is set for a "-" prefix
+	Sort string
+	Desc bool
+	// After is where the cursor points, nil without one
+	After        *pageCursor
+	IncludeTotal bool
+}
+
+// pageCursor is what a next_cursor encodes: the id of the last item of the
+// page and its value of the column sorted by, either name or created_at.
+type pageCursor struct {
+	Sort      string    `json:"sort"`
+	ID        int64     `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// parsePageParams reads the PageParams of query, reporting invalid ones to
+// v. sorts are the columns the collection can be sorted by, the first one is
+// the default.
+func parsePageParams(v *Validator, query url.Values, sorts ...string) PageParams {
+	p := PageParams{Limit: defaultPageSize, Sort: sorts[0]}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		v.Check("limit", err == nil && n >= 1 && n <= maxPageSize, "limit must be between 1 and "+strconv.Itoa(maxPageSize))
+		if err == nil {
+			p.Limit = n
+		}
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		p.Sort, p.Desc = sort, sort[0] == '-'
+		if p.Desc {
+			p.Sort = sort[1:]
+		}
+
+		ok := false
+		for _, s := range sorts {
+			ok = ok || p.Sort == s
+		}
+		v.Check("sort", ok, "sort must be one of "+joinOr(sorts)+", prefixed with - to sort descending")
+	}
+
+	if total := query.Get("include_total"); total != "" {
+		b, err := strconv.ParseBool(total)
+		v.Check("include_total", err == nil, "include total must be true or false")
+		p.IncludeTotal = b
+	}
+
+	// a cursor only points into the order it was made for
+	if cursor := query.Get("cursor"); cursor != "" {
+		var c pageCursor
+		ok := decodeCursor(cursor, &c) && c.Sort == p.sortParam()
+		v.Check("cursor", ok, "cursor must be the next cursor of a page sorted the same way")
+		if ok {
+			p.After = &c
+		}
+	}
+
+	return p
+}
+
+// nextCursor makes the next_cursor of a page sorted by p, whose last item
+// has id, name and createdAt.
+func (p PageParams) nextCursor(id int64, name string, createdAt time.Time) string {
+	c := pageCursor{Sort: p.sortParam(), ID: id}
+	if p.Sort == "name" {
+		c.Name = name
+	} else {
+		c.CreatedAt = createdAt
+	}
+
+	return encodeCursor(c)
+}
+
+// sortParam is how the sort parameter spells the order of p.
+func (p PageParams) sortParam() string {
+	if p.Desc {
+		return "-" + p.Sort
+	}
+
+	return p.Sort
+}
+
+// encodeCursor makes the next_cursor of a page from v, the position of its
+// last item. Clients should treat it as opaque.
+func encodeCursor(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads a cursor made by encodeCursor into v.
+func decodeCursor(cursor string, v any) bool {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	return err == nil && json.Unmarshal(b, v) == nil
+}
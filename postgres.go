@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// PostgresStorage runs the queries of Storage on Postgres, rewritten by
+// PostgresDialect.
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+func NewPostgresStorage(dsn string) *PostgresStorage {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := db.Ping(); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Connected to Postgres!")
+	return &PostgresStorage{
+		db: db,
+	}
+}
+
+func (s *PostgresStorage) Init() (*sql.DB, error) {
+	return migrateUp(s.db, PostgresMigrations)
+}
+
+func (s *PostgresStorage) Migrator() (*Migrator, error) {
+	return NewMigrator(s.db, PostgresMigrations)
+}
+
+// isPostgresDeadlock reports whether err aborted the transaction to break a
+// deadlock or a serialization conflict, both of which succeed when retried.
+func isPostgresDeadlock(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && (pqErr.Code == "40P01" || pqErr.Code == "40001")
+}
+
+// postgresErrorKind classifies the constraint violations of Postgres by
+// SQLSTATE.
+func postgresErrorKind(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return ErrConflict
+	case "23503": // foreign_key_violation
+		return ErrForeignKey
+	case "23502", "23514", "22001", "22003", "22P02": // not null, check, too long, out of range, not in the enum
+		return ErrValidation
+	}
+
+	return nil
+}
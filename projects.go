@@ -1,101 +1,227 @@
-package main
-
-import (
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
-)
-
-var errProjectNameRequired = errors.New("project name is required")
-
-type ProjectService struct {
-	store Store
-}
-
-func NewProjectService(s Store) *ProjectService {
-	return &ProjectService{
-		store: s,
-	}
-}
-
-func (s *ProjectService) RegisterRoutes(r *http.ServeMux) {
-	r.HandleFunc("POST /projects", WithJWTAuth(s.HandleProjectCreate, s.store))
-	r.HandleFunc("GET /projects/{project_id}", WithJWTAuth(s.HandleProjectGet, s.store))
-	r.HandleFunc("DELETE /projects/{project_id}", WithJWTAuth(s.HandleProjectDelete, s.store))
-}
-
-func (s *ProjectService) HandleProjectCreate(w http.ResponseWriter, r *http.Request) {
-	// read request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error reading request body"})
-		return
-	}
-
-	defer r.Body.Close()
-
-	// unmarshal request body into project struct
-	var payload *Project
-	err = json.Unmarshal(body, &payload)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "invalid request payload"})
-		return
-	}
-
-	// validate project payload
-	if err := validateProjectPayload(payload); err != nil {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "error validating project payload"})
-		return
-	}
-
-	// call store.CreateProject
-	p, err := s.store.CreateProject(payload)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error creating project"})
-		return
-	}
-
-	// write response
-	WriteJSON(w, http.StatusCreated, p)
-}
-
-func (s *ProjectService) HandleProjectGet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("project_id")
-	if id == "" {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "field id is missing"})
-		return
-	}
-
-	p, err := s.store.GetProjectByID(id)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error getting project"})
-		return
-	}
-
-	WriteJSON(w, http.StatusOK, p)
-}
-
-func (s *ProjectService) HandleProjectDelete(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("project_id")
-	if id == "" {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "field id is missing"})
-		return
-	}
-
-	d, err := s.store.DeleteProject(id)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error deleting project"})
-		return
-	}
-
-	WriteJSON(w, http.StatusNoContent, d)
-}
-
-func validateProjectPayload(p *Project) error {
-	if p.Name == "" {
-		return errProjectNameRequired
-	}
-
-	return nil
-}
+package main
+
+import (
+	"net/http"
+)
+
+type ProjectService struct {
+	store Store
+}
+
+func NewProjectService(s Store) *ProjectService {
+	return &ProjectService{
+		store: s,
+	}
+}
+
+func (s *ProjectService) RegisterRoutes(r *http.ServeMux) {
+	r.HandleFunc("GET /projects", WithJWTAuth(RequirePermission(PermProjectsRead, s.HandleProjectList), s.store))
+	r.HandleFunc("POST /projects", WithJWTAuth(RequirePermission(PermProjectsCreate, s.HandleProjectCreate), s.store))
+	r.HandleFunc("GET /projects/{project_id}", WithJWTAuth(RequirePermission(PermProjectsRead, s.HandleProjectGet), s.store))
+	r.HandleFunc("DELETE /projects/{project_id}", WithJWTAuth(RequirePermission(PermProjectsDelete, s.HandleProjectDelete), s.store))
+
+	// members...
+	r.HandleFunc("GET /projects/{project_id}/members", WithJWTAuth(RequirePermission(PermProjectsRead, s.HandleProjectMembersList), s.store))
+	r.HandleFunc("POST /projects/{project_id}/members", WithJWTAuth(RequirePermission(PermProjectsUpdate, s.HandleProjectMemberAdd), s.store))
+	r.HandleFunc("DELETE /projects/{project_id}/members/{user_id}", WithJWTAuth(RequirePermission(PermProjectsUpdate, s.HandleProjectMemberRemove), s.store))
+
+	// statuses...
+	r.HandleFunc("GET /projects/{project_id}/statuses", WithJWTAuth(RequirePermission(PermProjectsRead, s.HandleStatusList), s.store))
+	r.HandleFunc("POST /projects/{project_id}/statuses", WithJWTAuth(RequirePermission(PermProjectsUpdate, s.HandleStatusCreate), s.store))
+	r.HandleFunc("GET /projects/{project_id}/statuses/{status_id}", WithJWTAuth(RequirePermission(PermProjectsRead, s.HandleStatusGet), s.store))
+	r.HandleFunc("PATCH /projects/{project_id}/statuses/{status_id}", WithJWTAuth(RequirePermission(PermProjectsUpdate, s.HandleStatusUpdate), s.store))
+	r.HandleFunc("DELETE /projects/{project_id}/statuses/{status_id}", WithJWTAuth(RequirePermission(PermProjectsUpdate, s.HandleStatusDelete), s.store))
+
+	// workflow...
+	r.HandleFunc("GET /projects/{project_id}/workflow", WithJWTAuth(RequirePermission(PermProjectsRead, s.HandleWorkflowGet), s.store))
+	r.HandleFunc("PUT /projects/{project_id}/workflow", WithJWTAuth(RequirePermission(PermProjectsUpdate, s.HandleWorkflowReplace), s.store))
+}
+
+func (s *ProjectService) HandleProjectCreate(w http.ResponseWriter, r *http.Request) {
+	// read request body
+	var payload CreateProjectPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	// validate project payload
+	if err := validateProjectPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// the project never exists without its owner
+	var p *Project
+	err := s.store.WithTx(r.Context(), func(tx Store) error {
+		var err error
+		p, err = tx.CreateProject(r.Context(), orgID, &Project{Name: payload.Name, CreatedBy: &u.ID})
+		if err != nil {
+			return err
+		}
+
+		// the creator owns the project
+		_, err = tx.AddProjectMember(r.Context(), orgID, &ProjectMember{ProjectID: p.ID, UserID: u.ID, Role: ProjectRoleOwner})
+		return err
+	})
+	if err != nil {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	// write response
+	WriteJSON(w, http.StatusCreated, newProjectResponse(p))
+}
+
+// HandleProjectList lists the projects of the organization: all of them for
+// admins, the ones they are a member of for everyone else.
+func (s *ProjectService) HandleProjectList(w http.ResponseWriter, r *http.Request) {
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	q, params, err := parseProjectQuery(r)
+	if err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	if u.Role != RoleAdmin {
+		q.MemberID = u.ID
+	}
+
+	page, err := s.store.ListProjects(r.Context(), orgID, q)
+	if err != nil {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	res := PageResponse[ProjectResponse]{Items: make([]ProjectResponse, 0, len(page.Projects))}
+	for _, p := range page.Projects {
+		res.Items = append(res.Items, newProjectResponse(p))
+	}
+
+	if page.More {
+		last := page.Projects[len(page.Projects)-1]
+		res.NextCursor = params.nextCursor(last.ID, last.Name, last.CreatedAt)
+	}
+
+	if q.CountTotal {
+		res.Total = &page.Total
+	}
+
+	WriteJSON(w, http.StatusOK, res)
+}
+
+// parseProjectQuery reads the query parameters of GET /projects: those of
+// parsePageParams, name_prefix and search.
+func parseProjectQuery(r *http.Request) (ProjectQuery, PageParams, error) {
+	var v Validator
+	query := r.URL.Query()
+	params := parsePageParams(&v, query, string(ProjectSortCreatedAt), string(ProjectSortName))
+
+	q := ProjectQuery{
+		NamePrefix: query.Get("name_prefix"),
+		Search:     query.Get("search"),
+		Sort:       ProjectSort(params.Sort),
+		Desc:       params.Desc,
+		Limit:      params.Limit,
+		CountTotal: params.IncludeTotal,
+	}
+
+	v.String("name_prefix", q.NamePrefix).MaxLen(maxNameLength)
+	v.String("search", q.Search).MaxLen(maxNameLength)
+
+	if c := params.After; c != nil {
+		q.After = &Project{ID: c.ID, Name: c.Name, CreatedAt: c.CreatedAt}
+	}
+
+	return q, params, v.Err()
+}
+
+func (s *ProjectService) HandleProjectGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("project_id")
+	if id == "" {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "field id is missing")
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectRead); !ok {
+		return
+	}
+
+	p, err := s.store.GetProjectByID(r.Context(), orgID, id)
+	if err != nil {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, newProjectResponse(p))
+}
+
+func (s *ProjectService) HandleProjectDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("project_id")
+	if id == "" {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "field id is missing")
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectDelete); !ok {
+		return
+	}
+
+	d, err := s.store.DeleteProject(r.Context(), orgID, id)
+	if err == nil && d == 0 {
+		err = ErrNotFound
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	WriteJSON(w, http.StatusNoContent, d)
+}
+
+func validateProjectPayload(p *CreateProjectPayload) error {
+	var v Validator
+	v.String("name", p.Name).Required().MaxLen(maxNameLength)
+	return v.Err()
+}
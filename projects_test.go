@@ -1,136 +1,261 @@
-package main
-
-import (
-	"bytes"
-	"encoding/json"
-	"net/http"
-	"net/http/httptest"
-	"testing"
-)
-
-func TestCreateProject(t *testing.T) {
-	ms := &MockStore{}
-	service := NewProjectService(ms)
-
-	t.Run("shoud create a project", func(t *testing.T) {
-		payload := &CreateProjectPayload{
-			Name: "NO_NAME",
-		}
-
-		b, err := json.Marshal(payload)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		req, err := http.NewRequest(http.MethodPost, "/projects", bytes.NewBuffer(b))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("/projects", service.HandleProjectCreate)
-
-		router.ServeHTTP(rr, req)
-
-		if rr.Code != http.StatusCreated {
-			t.Error("invalid status code, it should pass")
-		}
-	})
-
-	t.Run("should validate if name is a empty field", func(t *testing.T) {
-		payload := &CreateProjectPayload{
-			Name: "",
-		}
-
-		b, err := json.Marshal(payload)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		req, err := http.NewRequest(http.MethodPost, "/projects", bytes.NewBuffer(b))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("/projects", service.HandleProjectCreate)
-
-		router.ServeHTTP(rr, req)
-
-		if rr.Code != http.StatusBadRequest {
-			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
-		}
-
-		// var response ErrorResponse
-		// err = json.NewDecoder(rr.Body).Decode(&response)
-		// if err != nil {
-		// 	t.Fatal(err)
-		// }
-
-		// if response.Error != errProjectNameRequired.Error() {
-		// 	t.Errorf("expected error message %s, got %s", errProjectNameRequired, response.Error)
-		// }
-	})
-}
-
-func TestGetProject(t *testing.T) {
-
-	ms := &MockStore{}
-	service := NewProjectService(ms)
-
-	t.Run("should return a project", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodGet, "/projects/1", nil)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("/projects/{project_id}", service.HandleProjectGet)
-
-		router.ServeHTTP(rr, req)
-
-		if rr.Code != http.StatusOK {
-			t.Error("invalid status code, it should pass")
-		}
-
-		// var response Project
-		// err = json.NewDecoder(rr.Body).Decode(&response)
-		// if err != nil {
-		// 	t.Fatal(err)
-		// }
-
-		// if response.Name != "NO_NAME" {
-		// 	t.Errorf("expected project name %s, got %s", "my new project", response.Name)
-		// }
-	})
-}
-
-func TestDeleteProject(t *testing.T) {
-
-	ms := &MockStore{}
-	service := NewProjectService(ms)
-
-	t.Run("should delete the project", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodDelete, "/projects", nil)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("/projects", service.HandleProjectDelete)
-
-		router.ServeHTTP(rr, req)
-
-		if rr.Code != http.StatusBadRequest {
-			t.Errorf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
-		}
-	})
-}
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// projectRoutes serves the project handlers on store without the auth
+// middleware.
+func projectRoutes(store Store) http.Handler {
+	router := http.NewServeMux()
+
+	service := NewProjectService(store)
+	router.HandleFunc("GET /projects", service.HandleProjectList)
+	router.HandleFunc("POST /projects", service.HandleProjectCreate)
+	router.HandleFunc("GET /projects/{project_id}", service.HandleProjectGet)
+	router.HandleFunc("DELETE /projects/{project_id}", service.HandleProjectDelete)
+
+	return router
+}
+
+func TestCreateProject(t *testing.T) {
+	ms := &MockStore{}
+
+	t.Run("shoud create a project", func(t *testing.T) {
+		payload := &CreateProjectPayload{
+			Name: "NO_NAME",
+		}
+
+		rr := serveAs(t, projectRoutes(ms), 7, http.MethodPost, "/projects", payload)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if p := ms.projects[len(ms.projects)-1]; p.CreatedBy == nil || *p.CreatedBy != 7 {
+			t.Errorf("expected project to be created by user 7, got %v", p.CreatedBy)
+		}
+
+		if m := ms.members[len(ms.members)-1]; m.UserID != 7 || m.Role != ProjectRoleOwner {
+			t.Errorf("expected user 7 to own the project, got %+v", m)
+		}
+	})
+
+	t.Run("should require an authenticated user", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		projectRoutes(ms).ServeHTTP(rr, newJSONRequest(t, http.MethodPost, "/projects", &CreateProjectPayload{Name: "NO_NAME"}))
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should validate if name is a empty field", func(t *testing.T) {
+		rr := serveAs(t, projectRoutes(ms), 7, http.MethodPost, "/projects", &CreateProjectPayload{Name: ""})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should not create a project without its owner", func(t *testing.T) {
+		fs := &failingMemberStore{MockStore: &MockStore{}}
+
+		rr := serveAs(t, projectRoutes(fs), 7, http.MethodPost, "/projects", &CreateProjectPayload{Name: "NO_NAME"})
+
+		if rr.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+
+		if len(fs.projects) != 0 {
+			t.Errorf("expected the project to be rolled back, got %+v", fs.projects)
+		}
+	})
+}
+
+// failingMemberStore fails to add project members, also inside transactions.
+type failingMemberStore struct {
+	*MockStore
+}
+
+func (f *failingMemberStore) AddProjectMember(ctx context.Context, orgID int64, m *ProjectMember) (*ProjectMember, error) {
+	return nil, errors.New("adding member failed")
+}
+
+func (f *failingMemberStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	return f.MockStore.WithTx(ctx, func(tx Store) error {
+		return fn(f)
+	})
+}
+
+func TestGetProject(t *testing.T) {
+
+	ms := &MockStore{
+		projects: []*Project{{ID: 1, OrgID: testOrgID}},
+		members: []*ProjectMember{
+			{ProjectID: 1, UserID: 1, Role: ProjectRoleViewer},
+		},
+	}
+
+	t.Run("should forbid users outside the project", func(t *testing.T) {
+		rr := serveAs(t, projectRoutes(ms), 2, http.MethodGet, "/projects/1", nil)
+
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should return a project", func(t *testing.T) {
+		rr := serveAs(t, projectRoutes(ms), 1, http.MethodGet, "/projects/1", nil)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+}
+
+func TestListProjects(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := &MockStore{
+		projects: []*Project{
+			{ID: 1, OrgID: testOrgID, Name: "website", CreatedAt: created},
+			{ID: 2, OrgID: testOrgID, Name: "backend", CreatedAt: created.Add(time.Hour)},
+			{ID: 3, OrgID: testOrgID, Name: "Web app", CreatedAt: created.Add(2 * time.Hour)},
+			{ID: 4, OrgID: testOrgID, Name: "secret", CreatedAt: created.Add(3 * time.Hour)},
+			{ID: 5, OrgID: testOrgID + 1, Name: "elsewhere", CreatedAt: created},
+		},
+		members: []*ProjectMember{
+			{ProjectID: 1, UserID: 1, Role: ProjectRoleViewer},
+			{ProjectID: 2, UserID: 1, Role: ProjectRoleOwner},
+			{ProjectID: 3, UserID: 1, Role: ProjectRoleContributor},
+		},
+	}
+	list := func(t *testing.T, u *User, query string) (*httptest.ResponseRecorder, PageResponse[ProjectResponse]) {
+		rr := serveAsUser(t, projectRoutes(ms), u, http.MethodGet, "/projects?"+query, nil)
+
+		var page PageResponse[ProjectResponse]
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return rr, page
+	}
+
+	names := func(page PageResponse[ProjectResponse]) []string {
+		names := []string{}
+		for _, p := range page.Items {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	t.Run("should page through the projects of the member", func(t *testing.T) {
+		member := &User{ID: 1, Role: RoleMember}
+
+		got := []string{}
+		query := "limit=2&sort=-created_at"
+		for pages := 0; ; pages++ {
+			rr, page := list(t, member, query)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+			}
+
+			got = append(got, names(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+
+			if pages > 3 {
+				t.Fatal("expected the pages to end")
+			}
+			query = "limit=2&sort=-created_at&cursor=" + page.NextCursor
+		}
+
+		want := []string{"Web app", "backend", "website"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected projects %v, got %v", want, got)
+		}
+	})
+
+	t.Run("should filter and count the projects of the organization for admins", func(t *testing.T) {
+		rr, page := list(t, &User{ID: 9, Role: RoleAdmin}, "name_prefix=WEB&sort=name&include_total=true&limit=1")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if got := names(page); !reflect.DeepEqual(got, []string{"Web app"}) || page.NextCursor == "" {
+			t.Errorf("expected the page [Web app] with a next cursor, got %v %q", got, page.NextCursor)
+		}
+
+		if page.Total == nil || *page.Total != 2 {
+			t.Errorf("expected a total of 2, got %v", page.Total)
+		}
+
+		if _, page := list(t, &User{ID: 9, Role: RoleAdmin}, "search=CRE"); !reflect.DeepEqual(names(page), []string{"secret"}) || page.Total != nil {
+			t.Errorf("expected [secret] without a total, got %v %v", names(page), page.Total)
+		}
+	})
+
+	t.Run("should reject invalid parameters", func(t *testing.T) {
+		_, page := list(t, &User{ID: 1, Role: RoleMember}, "limit=1&sort=name")
+
+		rr, _ := list(t, &User{ID: 1, Role: RoleMember}, "limit=500&sort=id&include_total=maybe&cursor="+page.NextCursor)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		fields := []string{}
+		for _, f := range p.Errors {
+			fields = append(fields, f.Field)
+		}
+
+		// the cursor was made for sorting by name
+		if want := []string{"limit", "sort", "include_total", "cursor"}; !reflect.DeepEqual(fields, want) {
+			t.Errorf("expected errors for %v, got %+v", want, p.Errors)
+		}
+	})
+}
+
+func TestDeleteProject(t *testing.T) {
+
+	ms := &MockStore{
+		projects: []*Project{{ID: 1, OrgID: testOrgID}},
+		members: []*ProjectMember{
+			{ProjectID: 1, UserID: 1, Role: ProjectRoleOwner},
+		},
+	}
+
+	t.Run("should delete the project", func(t *testing.T) {
+		rr := serveAs(t, projectRoutes(ms), 1, http.MethodDelete, "/projects/1", nil)
+
+		if rr.Code != http.StatusNoContent {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+
+		if len(ms.projects) != 0 {
+			t.Errorf("expected the project to be deleted, got %+v", ms.projects)
+		}
+	})
+
+	t.Run("should reject an invalid project id", func(t *testing.T) {
+		rr := serveAs(t, projectRoutes(ms), 1, http.MethodDelete, "/projects/abc", nil)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// RevocationCache wraps a Store and answers IsTokenRevoked from memory, so
+// WithJWTAuth doesn't query the denylist on every request. Negative answers
+// are only trusted for ttl, which bounds how long a token revoked by another
+// instance keeps working here.
+type RevocationCache struct {
+	Store
+
+	ttl time.Duration
+	// maxEntries bounds entries, which would otherwise grow with every jti
+	// seen between two cleanups
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[string]revocationEntry
+}
+
+// maxRevocationEntries is the maxEntries of a new RevocationCache.
+const maxRevocationEntries = 10000
+
+type revocationEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+func NewRevocationCache(s Store, ttl time.Duration) *RevocationCache {
+	return &RevocationCache{
+		Store:      s,
+		ttl:        ttl,
+		maxEntries: maxRevocationEntries,
+		entries:    make(map[string]revocationEntry),
+	}
+}
+
+func (c *RevocationCache) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[jti]
+	if ok && !now.Before(e.expiresAt) {
+		delete(c.entries, jti)
+	}
+	c.mu.Unlock()
+
+	if ok && now.Before(e.expiresAt) {
+		return e.revoked, nil
+	}
+
+	revoked, err := c.Store.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	c.set(jti, revoked, now)
+	return revoked, nil
+}
+
+func (c *RevocationCache) RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error {
+	if err := c.Store.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+
+	c.set(jti, true, time.Now())
+	return nil
+}
+
+func (c *RevocationCache) DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error) {
+	c.mu.Lock()
+	for jti, e := range c.entries {
+		if e.expiresAt.Before(before) {
+			delete(c.entries, jti)
+		}
+	}
+	c.mu.Unlock()
+
+	return c.Store.DeleteExpiredRevokedTokens(ctx, before)
+}
+
+// set caches the answer for jti. A revoked token stays revoked: a lookup
+// that read the store before a RevokeToken may only answer after it. A full
+// cache first drops its expired entries, and everything when none are: it is
+// only a cache, the store still has the answers.
+func (c *RevocationCache) set(jti string, revoked bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[jti]; ok && e.revoked && !revoked {
+		return
+	}
+
+	if _, ok := c.entries[jti]; !ok && len(c.entries) >= c.maxEntries {
+		for jti, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, jti)
+			}
+		}
+
+		if len(c.entries) >= c.maxEntries {
+			clear(c.entries)
+		}
+	}
+
+	c.entries[jti] = revocationEntry{revoked: revoked, expiresAt: now.Add(c.ttl)}
+}
+
+// cleanupRevokedTokens periodically drops denylist entries whose tokens
+// have expired on their own. interval has to be positive.
+func cleanupRevokedTokens(store Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := store.DeleteExpiredRevokedTokens(context.Background(), time.Now())
+		if err != nil {
+			log.Println("error cleaning up revoked tokens: ", err)
+			continue
+		}
+
+		if n > 0 {
+			log.Printf("removed %d expired revoked tokens", n)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type countingStore struct {
+	*MockStore
+	lookups int
+}
+
+func (c *countingStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	c.lookups++
+	return c.MockStore.IsTokenRevoked(ctx, jti)
+}
+
+// stalledStore reads that no token is revoked, then waits to answer until
+// resume is closed.
+type stalledStore struct {
+	*MockStore
+	read   chan struct{}
+	resume chan struct{}
+}
+
+func (s *stalledStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	close(s.read)
+	<-s.resume
+	return false, nil
+}
+
+func TestRevocationCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should only hit the store once per ttl", func(t *testing.T) {
+		cs := &countingStore{MockStore: &MockStore{}}
+		cache := NewRevocationCache(cs, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			revoked, err := cache.IsTokenRevoked(ctx, "jti")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if revoked {
+				t.Error("expected the token not to be revoked")
+			}
+		}
+
+		if cs.lookups != 1 {
+			t.Errorf("expected 1 store lookup, got %d", cs.lookups)
+		}
+	})
+
+	t.Run("should keep a revocation a stale lookup answers after", func(t *testing.T) {
+		ss := &stalledStore{MockStore: &MockStore{}, read: make(chan struct{}), resume: make(chan struct{})}
+		cache := NewRevocationCache(ss, time.Minute)
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := cache.IsTokenRevoked(ctx, "jti")
+			done <- err
+		}()
+		<-ss.read
+
+		if err := cache.RevokeToken(ctx, "jti", 1, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		close(ss.resume)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+
+		revoked, err := cache.IsTokenRevoked(ctx, "jti")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !revoked {
+			t.Error("expected the token to stay revoked")
+		}
+	})
+
+	t.Run("should see its own revocations immediately", func(t *testing.T) {
+		cs := &countingStore{MockStore: &MockStore{}}
+		cache := NewRevocationCache(cs, time.Minute)
+
+		if _, err := cache.IsTokenRevoked(ctx, "jti"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := cache.RevokeToken(ctx, "jti", 1, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		revoked, err := cache.IsTokenRevoked(ctx, "jti")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !revoked {
+			t.Error("expected the token to be revoked")
+		}
+
+		if cs.lookups != 1 {
+			t.Errorf("expected 1 store lookup, got %d", cs.lookups)
+		}
+	})
+
+	t.Run("should look up again once the entry expired", func(t *testing.T) {
+		cs := &countingStore{MockStore: &MockStore{}}
+		cache := NewRevocationCache(cs, time.Millisecond)
+
+		if _, err := cache.IsTokenRevoked(ctx, "jti"); err != nil {
+			t.Fatal(err)
+		}
+
+		// revoked by another instance, bypassing this cache
+		if err := cs.RevokeToken(ctx, "jti", 1, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+
+		revoked, err := cache.IsTokenRevoked(ctx, "jti")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !revoked {
+			t.Error("expected the token to be revoked")
+		}
+	})
+
+	t.Run("should stay within its size", func(t *testing.T) {
+		cache := NewRevocationCache(&MockStore{}, time.Minute)
+		cache.maxEntries = 10
+
+		for i := 0; i < 25; i++ {
+			if _, err := cache.IsTokenRevoked(ctx, strconv.Itoa(i)); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(cache.entries) > cache.maxEntries {
+				t.Fatalf("expected at most %d entries, got %d", cache.maxEntries, len(cache.entries))
+			}
+		}
+	})
+
+	t.Run("should make room by dropping expired entries first", func(t *testing.T) {
+		cache := NewRevocationCache(&MockStore{}, time.Millisecond)
+		cache.maxEntries = 3
+
+		lookUp := func(t *testing.T, jtis ...string) {
+			for _, jti := range jtis {
+				if _, err := cache.IsTokenRevoked(ctx, jti); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		lookUp(t, "a", "b")
+		time.Sleep(5 * time.Millisecond)
+
+		cache.ttl = time.Minute
+		lookUp(t, "c", "d")
+
+		if _, ok := cache.entries["c"]; !ok || len(cache.entries) != 2 {
+			t.Errorf("expected only the live entries c and d, got %v", cache.entries)
+		}
+	})
+
+	t.Run("should drop expired entries on cleanup", func(t *testing.T) {
+		cache := NewRevocationCache(&MockStore{}, time.Millisecond)
+
+		if err := cache.RevokeToken(ctx, "jti", 1, time.Now().Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := cache.DeleteExpiredRevokedTokens(ctx, time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 1 {
+			t.Errorf("expected 1 deleted token, got %d", n)
+		}
+
+		if len(cache.entries) != 0 {
+			t.Errorf("expected the cache to be empty, got %d entries", len(cache.entries))
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleViewer Role = "viewer"
+)
+
+type Permission string
+
+const (
+	PermUsersRead    Permission = "users:read"
+	PermUsersReadAll Permission = "users:read:all"
+	PermUsersManage  Permission = "users:manage"
+
+	PermProjectsCreate Permission = "projects:create"
+	PermProjectsRead   Permission = "projects:read"
+	PermProjectsUpdate Permission = "projects:update"
+	PermProjectsDelete Permission = "projects:delete"
+
+	PermTasksCreate Permission = "tasks:create"
+	PermTasksRead   Permission = "tasks:read"
+	PermTasksUpdate Permission = "tasks:update"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermUsersRead, PermUsersReadAll, PermUsersManage,
+		PermProjectsCreate, PermProjectsRead, PermProjectsUpdate, PermProjectsDelete,
+		PermTasksCreate, PermTasksRead, PermTasksUpdate,
+	},
+	// what a member may do inside a given project is further limited by
+	// their ProjectRole there
+	RoleMember: {
+		PermUsersRead,
+		PermProjectsCreate, PermProjectsRead, PermProjectsUpdate, PermProjectsDelete,
+		PermTasksCreate, PermTasksRead, PermTasksUpdate,
+	},
+	RoleViewer: {
+		PermUsersRead,
+		PermProjectsRead,
+		PermTasksRead,
+	},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RequirePermission only lets requests through whose authenticated user's
+// role grants p. It reads the user WithJWTAuth put in the context, so it has
+// to be wrapped by it:
+//
+//	WithJWTAuth(RequirePermission(PermProjectsDelete, handler), store)
+func RequirePermission(p Permission, handlerFunc http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, ok := UserFromContext(r.Context())
+		if !ok {
+			WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+			return
+		}
+
+		if !u.Role.Can(p) {
+			log.Printf("user %d with role %q lacks permission %s", u.ID, u.Role, p)
+			WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+			return
+		}
+
+		handlerFunc(w, r)
+	}
+}
+
+// ProjectRole is a user's role within a single project, see project_members.
+type ProjectRole string
+
+const (
+	ProjectRoleOwner       ProjectRole = "owner"
+	ProjectRoleMaintainer  ProjectRole = "maintainer"
+	ProjectRoleContributor ProjectRole = "contributor"
+	ProjectRoleViewer      ProjectRole = "viewer"
+)
+
+type ProjectPermission string
+
+const (
+	ProjectRead           ProjectPermission = "project:read"
+	ProjectTasksWrite     ProjectPermission = "project:tasks:write"
+	ProjectMembersManage  ProjectPermission = "project:members:manage"
+	ProjectWorkflowManage ProjectPermission = "project:workflow:manage"
+	ProjectDelete         ProjectPermission = "project:delete"
+)
+
+var projectRolePermissions = map[ProjectRole][]ProjectPermission{
+	ProjectRoleOwner:       {ProjectRead, ProjectTasksWrite, ProjectMembersManage, ProjectWorkflowManage, ProjectDelete},
+	ProjectRoleMaintainer:  {ProjectRead, ProjectTasksWrite, ProjectMembersManage, ProjectWorkflowManage},
+	ProjectRoleContributor: {ProjectRead, ProjectTasksWrite},
+	ProjectRoleViewer:      {ProjectRead},
+}
+
+func (r ProjectRole) Valid() bool {
+	_, ok := projectRolePermissions[r]
+	return ok
+}
+
+func (r ProjectRole) Can(p ProjectPermission) bool {
+	for _, granted := range projectRolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// OrgRole is a user's role within an organization. Owners manage who
+// belongs to it, members work in its projects.
+type OrgRole string
+
+const (
+	OrgRoleOwner  OrgRole = "owner"
+	OrgRoleMember OrgRole = "member"
+)
+
+func (r OrgRole) Valid() bool {
+	return r == OrgRoleOwner || r == OrgRoleMember
+}
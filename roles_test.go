@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirePermission(t *testing.T) {
+	handler := RequirePermission(PermUsersManage, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name string
+		user *User
+		want int
+	}{
+		{
+			name: "should let an admin through",
+			user: &User{ID: 1, Role: RoleAdmin},
+			want: http.StatusOK,
+		},
+		{
+			name: "should forbid a member",
+			user: &User{ID: 2, Role: RoleMember},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "should forbid a viewer",
+			user: &User{ID: 3, Role: RoleViewer},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "should forbid an unknown role",
+			user: &User{ID: 4, Role: "superuser"},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "should reject an unauthenticated request",
+			user: nil,
+			want: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/users/2/role", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.user != nil {
+				req = authenticate(req, tt.user)
+			}
+
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRolePermissions(t *testing.T) {
+	tests := []struct {
+		role Role
+		perm Permission
+		want bool
+	}{
+		{RoleAdmin, PermUsersManage, true},
+		{RoleMember, PermUsersManage, false},
+		{RoleMember, PermProjectsCreate, true},
+		{RoleViewer, PermProjectsDelete, false},
+		{RoleMember, PermTasksCreate, true},
+		{RoleViewer, PermProjectsCreate, false},
+		{RoleViewer, PermTasksCreate, false},
+		{RoleViewer, PermTasksUpdate, false},
+		{RoleViewer, PermTasksRead, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.perm); got != tt.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestProjectRolePermissions(t *testing.T) {
+	tests := []struct {
+		role ProjectRole
+		perm ProjectPermission
+		want bool
+	}{
+		{ProjectRoleOwner, ProjectDelete, true},
+		{ProjectRoleMaintainer, ProjectDelete, false},
+		{ProjectRoleMaintainer, ProjectMembersManage, true},
+		{ProjectRoleContributor, ProjectMembersManage, false},
+		{ProjectRoleMaintainer, ProjectWorkflowManage, true},
+		{ProjectRoleContributor, ProjectWorkflowManage, false},
+		{ProjectRoleContributor, ProjectTasksWrite, true},
+		{ProjectRoleViewer, ProjectTasksWrite, false},
+		{ProjectRoleViewer, ProjectRead, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.perm); got != tt.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+var errRefreshTokenRequired = errors.New("refresh token is required")
+var errRefreshTokenInvalid = errors.New("invalid refresh token")
+var errRefreshTokenReused = errors.New("refresh token was already used")
+
+const refreshTokenCookie = "refresh_token"
+
+type AuthService struct {
+	store Store
+}
+
+func NewAuthService(s Store) *AuthService {
+	return &AuthService{
+		store: s,
+	}
+}
+
+func (s *AuthService) RegisterRoutes(r *http.ServeMux) {
+	r.HandleFunc("POST /auth/refresh", s.HandleRefresh)
+	r.HandleFunc("POST /auth/logout", WithJWTAuth(s.HandleLogout, s.store))
+	r.HandleFunc("POST /auth/logout-all", WithJWTAuth(s.HandleLogoutAll, s.store))
+}
+
+// HandleRefresh exchanges a refresh token for a new access/refresh token pair.
+// Every refresh token can be used exactly once; presenting one that was
+// already rotated means it leaked, so the whole family is revoked.
+func (s *AuthService) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	raw, p := getRefreshTokenFromRequest(w, r)
+	if p != nil {
+		writeProblem(w, r, p)
+		return
+	}
+
+	rt, err := s.store.GetRefreshTokenByHash(r.Context(), hashRefreshToken(raw))
+	if errors.Is(err, ErrNotFound) {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, errRefreshTokenInvalid.Error())
+		return
+	}
+
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error getting refresh token")
+		return
+	}
+
+	now := time.Now()
+
+	if rt.RevokedAt != nil {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, errRefreshTokenInvalid.Error())
+		return
+	}
+
+	if rt.UsedAt != nil {
+		s.revokeFamily(r.Context(), rt, now)
+		WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, errRefreshTokenInvalid.Error())
+		return
+	}
+
+	if now.After(rt.ExpiresAt) {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, errRefreshTokenInvalid.Error())
+		return
+	}
+
+	// the token is only used up along with creating its successor, a
+	// failure in between leaves it for the client to retry with
+	var tokens *TokenResponse
+	err = s.store.WithTx(r.Context(), func(tx Store) error {
+		ok, err := tx.UseRefreshToken(r.Context(), rt.ID, now)
+		if err != nil {
+			return err
+		}
+
+		// someone else rotated this token between our read and this update
+		if !ok {
+			return errRefreshTokenReused
+		}
+
+		tokens, err = newTokens(r.Context(), tx, rt.UserID, rt.FamilyID)
+		return err
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		s.revokeFamily(r.Context(), rt, now)
+		WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidToken, errRefreshTokenInvalid.Error())
+		return
+	}
+
+	if err != nil {
+		log.Println("error rotating refresh token: ", err)
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error rotating refresh token")
+		return
+	}
+
+	setTokenCookies(w, tokens)
+	WriteJSON(w, http.StatusOK, tokens)
+}
+
+// HandleLogout revokes the access token used for the request and, when the
+// client sends it along, the refresh token family of the same session.
+func (s *AuthService) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	u, ok := UserFromContext(r.Context())
+	claims, hasClaims := ClaimsFromContext(r.Context())
+	if !ok || !hasClaims {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	if err := s.store.RevokeToken(r.Context(), claims.Id, u.ID, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error revoking token")
+		return
+	}
+
+	if raw, p := getRefreshTokenFromRequest(w, r); p == nil {
+		rt, err := s.store.GetRefreshTokenByHash(r.Context(), hashRefreshToken(raw))
+		if err == nil && rt.UserID == u.ID {
+			if err := s.store.RevokeRefreshTokenFamily(r.Context(), rt.FamilyID, time.Now()); err != nil {
+				log.Println("error revoking refresh token family: ", err)
+			}
+		}
+	}
+
+	clearAuthCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleLogoutAll revokes every access and refresh token of the caller.
+func (s *AuthService) HandleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	// the columns keep microseconds, rounding up would cut off tokens issued
+	// right after
+	if err := s.store.RevokeUserTokens(r.Context(), u.ID, time.Now().Truncate(time.Microsecond)); err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error revoking tokens")
+		return
+	}
+
+	clearAuthCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeFamily revokes the family of a replayed refresh token. It isn't
+// cancelled with the request, whoever replayed the token may well hang up.
+func (s *AuthService) revokeFamily(ctx context.Context, rt *RefreshToken, at time.Time) {
+	log.Printf("refresh token reuse detected for user %d, revoking family %s", rt.UserID, rt.FamilyID)
+
+	if err := s.store.RevokeRefreshTokenFamily(context.WithoutCancel(ctx), rt.FamilyID, at); err != nil {
+		log.Println("error revoking refresh token family: ", err)
+	}
+}
+
+// issueTokens creates the tokens of newTokens and sets them as cookies.
+func issueTokens(ctx context.Context, w http.ResponseWriter, store Store, userID int64, familyID string) (*TokenResponse, error) {
+	tokens, err := newTokens(ctx, store, userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	setTokenCookies(w, tokens)
+	return tokens, nil
+}
+
+// newTokens creates a new access token and a refresh token belonging to
+// familyID. An empty familyID starts a new family, e.g. on login. The access
+// token works in the user's first organization, clients switch to another
+// one with the X-Org-ID header.
+func newTokens(ctx context.Context, store Store, userID int64, familyID string) (*TokenResponse, error) {
+	orgs, err := store.ListUserOrganizations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var orgID int64
+	if len(orgs) > 0 {
+		orgID = orgs[0].ID
+	}
+
+	token, err := CreateJWT(Keys.Current(), userID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		familyID, err = randomHex(16)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = store.CreateRefreshToken(ctx, &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(raw),
+		ExpiresAt: time.Now().Add(Envs.RefreshTokenExpiration),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		Token:        token,
+		RefreshToken: raw,
+		ExpiresIn:    int64(Envs.JWTExpiration.Seconds()),
+	}, nil
+}
+
+func setTokenCookies(w http.ResponseWriter, tokens *TokenResponse) {
+	http.SetCookie(w, &http.Cookie{
+		Name:  "Authorization",
+		Value: tokens.Token,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    tokens.RefreshToken,
+		Path:     "/api/v1/auth",
+		MaxAge:   int(Envs.RefreshTokenExpiration.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func clearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: "Authorization", Value: "", MaxAge: -1})
+	http.SetCookie(w, &http.Cookie{Name: refreshTokenCookie, Value: "", Path: "/api/v1/auth", MaxAge: -1})
+}
+
+// getRefreshTokenFromRequest reads the refresh token from the JSON body,
+// falling back to the cookie set by issueTokens, or returns the problem to
+// answer with.
+func getRefreshTokenFromRequest(w http.ResponseWriter, r *http.Request) (string, *Problem) {
+	// the body is optional, cookie based clients don't send one
+	var payload RefreshTokenPayload
+	if r.ContentLength != 0 {
+		if p := decodeJSON(w, r, &payload, "application/json"); p != nil {
+			return "", p
+		}
+	}
+
+	if payload.RefreshToken != "" {
+		return payload.RefreshToken, nil
+	}
+
+	if c, err := r.Cookie(refreshTokenCookie); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+
+	return "", invalidBody(errRefreshTokenRequired.Error())
+}
+
+// refresh tokens are opaque random strings, only their hash is persisted
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRefreshToken(t *testing.T) {
+	ctx := context.Background()
+
+	refresh := func(t *testing.T, service *AuthService, token string) *httptest.ResponseRecorder {
+		b, err := json.Marshal(&RefreshTokenPayload{RefreshToken: token})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+
+		router.HandleFunc("POST /auth/refresh", service.HandleRefresh)
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	login := func(t *testing.T, ms *MockStore) *TokenResponse {
+		tokens, err := issueTokens(ctx, httptest.NewRecorder(), ms, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tokens
+	}
+
+	t.Run("should rotate a valid refresh token", func(t *testing.T) {
+		ms := &MockStore{}
+		service := NewAuthService(ms)
+		tokens := login(t, ms)
+
+		rr := refresh(t, service, tokens.RefreshToken)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response TokenResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Token == "" || response.RefreshToken == "" {
+			t.Fatal("expected an access and a refresh token in the response")
+		}
+
+		if response.RefreshToken == tokens.RefreshToken {
+			t.Error("expected the refresh token to be rotated")
+		}
+
+		if ms.refreshTokens[0].FamilyID != ms.refreshTokens[1].FamilyID {
+			t.Error("expected the rotated token to stay in the same family")
+		}
+	})
+
+	t.Run("should revoke the family when a used token is presented again", func(t *testing.T) {
+		ms := &MockStore{}
+		service := NewAuthService(ms)
+		tokens := login(t, ms)
+
+		rr := refresh(t, service, tokens.RefreshToken)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var rotated TokenResponse
+		if err := json.NewDecoder(rr.Body).Decode(&rotated); err != nil {
+			t.Fatal(err)
+		}
+
+		// the attacker replays the old token...
+		if rr := refresh(t, service, tokens.RefreshToken); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+
+		// ...which also kills the token the legitimate client got
+		if rr := refresh(t, service, rotated.RefreshToken); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should not touch other families on reuse", func(t *testing.T) {
+		ms := &MockStore{}
+		service := NewAuthService(ms)
+		first := login(t, ms)
+		second := login(t, ms)
+
+		refresh(t, service, first.RefreshToken)
+		refresh(t, service, first.RefreshToken)
+
+		if rr := refresh(t, service, second.RefreshToken); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should keep the refresh token when issuing its successor fails", func(t *testing.T) {
+		ms := &MockStore{}
+		service := NewAuthService(ms)
+		tokens := login(t, ms)
+
+		ms.errs = map[string]error{"CreateRefreshToken": errors.New("connection refused")}
+		if rr := refresh(t, service, tokens.RefreshToken); rr.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+		}
+
+		// the retry isn't taken for a reuse
+		ms.errs = nil
+		if rr := refresh(t, service, tokens.RefreshToken); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should reject an expired refresh token", func(t *testing.T) {
+		ms := &MockStore{}
+		service := NewAuthService(ms)
+		tokens := login(t, ms)
+		ms.refreshTokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+
+		if rr := refresh(t, service, tokens.RefreshToken); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should reject an unknown refresh token", func(t *testing.T) {
+		service := NewAuthService(&MockStore{})
+
+		if rr := refresh(t, service, "not-a-token"); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("should validate if refresh token is empty", func(t *testing.T) {
+		service := NewAuthService(&MockStore{})
+
+		if rr := refresh(t, service, ""); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
+
+func TestLogout(t *testing.T) {
+	ctx := context.Background()
+
+	protected := func(store Store) http.HandlerFunc {
+		return WithJWTAuth(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}, store)
+	}
+
+	call := func(t *testing.T, handler http.Handler, method, path, token string, body []byte) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, bytes.NewBuffer(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	newRouter := func(ms *MockStore) *http.ServeMux {
+		router := http.NewServeMux()
+		NewAuthService(ms).RegisterRoutes(router)
+		router.HandleFunc("GET /protected", protected(ms))
+		return router
+	}
+
+	t.Run("should revoke the current token on logout", func(t *testing.T) {
+		ms := &MockStore{users: []*User{{ID: 1}}}
+		router := newRouter(ms)
+
+		tokens, err := issueTokens(ctx, httptest.NewRecorder(), ms, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		other, err := issueTokens(ctx, httptest.NewRecorder(), ms, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := json.Marshal(&RefreshTokenPayload{RefreshToken: tokens.RefreshToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr := call(t, router, http.MethodPost, "/auth/logout", tokens.Token, b); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		if rr := call(t, router, http.MethodGet, "/protected", tokens.Token, nil); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+
+		if ms.refreshTokens[0].RevokedAt == nil {
+			t.Error("expected the session's refresh token to be revoked")
+		}
+
+		// other sessions of the same user are not affected
+		if rr := call(t, router, http.MethodGet, "/protected", other.Token, nil); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should revoke every session on logout-all", func(t *testing.T) {
+		ms := &MockStore{users: []*User{{ID: 1}}}
+		router := newRouter(ms)
+
+		first, err := issueTokens(ctx, httptest.NewRecorder(), ms, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := issueTokens(ctx, httptest.NewRecorder(), ms, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr := call(t, router, http.MethodPost, "/auth/logout-all", first.Token, nil); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		for _, token := range []string{first.Token, second.Token} {
+			if rr := call(t, router, http.MethodGet, "/protected", token, nil); rr.Code != http.StatusUnauthorized {
+				t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+			}
+		}
+
+		for _, rt := range ms.refreshTokens {
+			if rt.RevokedAt == nil {
+				t.Error("expected every refresh token to be revoked")
+			}
+		}
+	})
+
+	t.Run("should accept tokens issued right after logout-all", func(t *testing.T) {
+		ms := &MockStore{users: []*User{{ID: 1}}}
+		router := newRouter(ms)
+
+		tokens, err := issueTokens(ctx, httptest.NewRecorder(), ms, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr := call(t, router, http.MethodPost, "/auth/logout-all", tokens.Token, nil); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d", http.StatusNoContent, rr.Code)
+		}
+
+		// logging in again within the same second
+		again, err := issueTokens(ctx, httptest.NewRecorder(), ms, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rr := call(t, router, http.MethodGet, "/protected", again.Token, nil); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("should require a token", func(t *testing.T) {
+		router := newRouter(&MockStore{})
+
+		if rr := call(t, router, http.MethodPost, "/auth/logout", "", nil); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code %d, got %d", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
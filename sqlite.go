@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteStorage is an embedded alternative to MySQL for local development
+// and tests. The queries of Storage run unchanged on it, see SQLiteDialect.
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+func NewSQLiteStorage(path string) *SQLiteStorage {
+	db, err := openSQLite(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("Opened SQLite database", path)
+	return &SQLiteStorage{
+		db: db,
+	}
+}
+
+// openSQLite opens the database file at path, creating it if needed. Foreign
+// keys are off by default in SQLite, we rely on them like on MySQL.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (s *SQLiteStorage) Init() (*sql.DB, error) {
+	return migrateUp(s.db, SQLiteMigrations)
+}
+
+func (s *SQLiteStorage) Migrator() (*Migrator, error) {
+	return NewMigrator(s.db, SQLiteMigrations)
+}
+
+// isSQLiteBusy reports whether err is SQLite refusing a write because another
+// connection holds the lock. Inside a transaction that already read, waiting
+// can't help and SQLite fails at once, the whole transaction has to retry.
+func isSQLiteBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy
+}
+
+// sqliteErrorKind classifies the constraint violations of SQLite.
+func sqliteErrorKind(err error) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return ErrConflict
+	case sqlite3.ErrConstraintForeignKey:
+		return ErrForeignKey
+	case sqlite3.ErrConstraintNotNull, sqlite3.ErrConstraintCheck:
+		return ErrValidation
+	}
+
+	return nil
+}
+
+// sqliteTime formats t like CURRENT_TIMESTAMP writes the created_at columns.
+// SQLite compares times as text, and the driver would add a fraction and a
+// time zone that break comparing for equality.
+func sqliteTime(t time.Time) any {
+	return t.UTC().Format(time.DateTime)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// maxStatusNameLength is the size of the VARCHAR(64) project_statuses.name.
+const maxStatusNameLength = 64
+
+var errLastStatus = &StoreError{Kind: ErrConflict, Message: "a project needs at least one status"}
+
+var errLastTransitions = &StoreError{Kind: ErrConflict, Message: "the workflow needs a transition without the status"}
+
+// StatusCategory groups the statuses of projects, whatever they are called,
+// into work that is yet to start, underway or finished.
+type StatusCategory string
+
+const (
+	StatusCategoryTodo       StatusCategory = "todo"
+	StatusCategoryInProgress StatusCategory = "in_progress"
+	StatusCategoryDone       StatusCategory = "done"
+)
+
+var statusCategories = []string{string(StatusCategoryTodo), string(StatusCategoryInProgress), string(StatusCategoryDone)}
+
+func (c StatusCategory) Valid() bool {
+	return c == StatusCategoryTodo || c == StatusCategoryInProgress || c == StatusCategoryDone
+}
+
+// defaultStatuses are the statuses every project starts with, the values
+// tasks.status had before projects had statuses of their own. New tasks
+// start in the first status of their project.
+var defaultStatuses = []ProjectStatus{
+	{Name: "TODO", Category: StatusCategoryTodo, Position: 0},
+	{Name: "IN_PROGRESS", Category: StatusCategoryInProgress, Position: 1},
+	{Name: "IN_TESTING", Category: StatusCategoryInProgress, Position: 2},
+	{Name: "DONE", Category: StatusCategoryDone, Position: 3},
+}
+
+func (s *ProjectService) HandleStatusList(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectRead); !ok {
+		return
+	}
+
+	statuses, err := s.store.ListProjectStatuses(r.Context(), orgID, projectID)
+	if err != nil {
+		WriteStoreError(w, r, err, "status")
+		return
+	}
+
+	res := make([]ProjectStatusResponse, 0, len(statuses))
+	for _, st := range statuses {
+		res = append(res, newProjectStatusResponse(st))
+	}
+
+	WriteJSON(w, http.StatusOK, res)
+}
+
+func (s *ProjectService) HandleStatusGet(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := statusIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectRead); !ok {
+		return
+	}
+
+	st, err := s.store.GetProjectStatus(r.Context(), orgID, projectID, id)
+	if err != nil {
+		WriteStoreError(w, r, err, "status")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, newProjectStatusResponse(st))
+}
+
+func (s *ProjectService) HandleStatusCreate(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload CreateProjectStatusPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateStatusPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectWorkflowManage); !ok {
+		return
+	}
+
+	st := &ProjectStatus{ProjectID: projectID, Name: payload.Name, Category: payload.Category}
+	err := s.store.WithTx(r.Context(), func(tx Store) error {
+		// statuses added alongside this one don't end up in the same place
+		if err := tx.LockProject(r.Context(), orgID, projectID); err != nil {
+			return err
+		}
+
+		if payload.Position != nil {
+			st.Position = *payload.Position
+		} else {
+			statuses, err := tx.ListProjectStatuses(r.Context(), orgID, projectID)
+			if err != nil {
+				return err
+			}
+
+			if len(statuses) > 0 {
+				st.Position = statuses[len(statuses)-1].Position + 1
+			}
+		}
+
+		var err error
+		st, err = tx.CreateProjectStatus(r.Context(), orgID, st)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "status")
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, newProjectStatusResponse(st))
+}
+
+// HandleStatusUpdate applies a JSON Merge Patch to the status. Tasks in it
+// keep it, whatever it becomes.
+func (s *ProjectService) HandleStatusUpdate(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := statusIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload UpdateProjectStatusPayload
+	if !DecodeMergePatch(w, r, &payload) {
+		return
+	}
+
+	if err := validateUpdateStatusPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectWorkflowManage); !ok {
+		return
+	}
+
+	st, err := s.store.UpdateProjectStatus(r.Context(), orgID, projectID, id, ProjectStatusUpdate{
+		Name:     payload.Name.Ptr(),
+		Category: payload.Category.Ptr(),
+		Position: payload.Position.Ptr(),
+	})
+	if err != nil {
+		WriteStoreError(w, r, err, "status")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, newProjectStatusResponse(st))
+}
+
+// HandleStatusDelete deletes a status no task is in. The transitions of the
+// workflow from and to it go with it, as long as they aren't all there is to
+// a configured workflow: without them it would be the default one.
+func (s *ProjectService) HandleStatusDelete(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	id, ok := statusIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectWorkflowManage); !ok {
+		return
+	}
+
+	if err := deleteProjectStatus(r.Context(), s.store, orgID, projectID, id); err != nil {
+		WriteStoreError(w, r, err, "status")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteProjectStatus deletes status id of the project unless it's the last
+// one or all there is to the configured workflow. The project stays locked
+// from the checks to the delete, so deletes running alongside this one
+// can't each leave the other the last status.
+func deleteProjectStatus(ctx context.Context, store Store, orgID, projectID, id int64) error {
+	return store.WithTx(ctx, func(tx Store) error {
+		if err := tx.LockProject(ctx, orgID, projectID); err != nil {
+			return err
+		}
+
+		statuses, err := tx.ListProjectStatuses(ctx, orgID, projectID)
+		if err != nil {
+			return err
+		}
+
+		if !slices.ContainsFunc(statuses, func(st *ProjectStatus) bool { return st.ID == id }) {
+			return ErrNotFound
+		}
+
+		// new tasks need a status to start in
+		if len(statuses) == 1 {
+			return errLastStatus
+		}
+
+		transitions, err := tx.ListWorkflowTransitions(ctx, orgID, projectID)
+		if err != nil {
+			return err
+		}
+
+		if len(transitions) > 0 && !slices.ContainsFunc(transitions, func(tr WorkflowTransition) bool {
+			return tr.FromStatusID != id && tr.ToStatusID != id
+		}) {
+			return errLastTransitions
+		}
+
+		_, err = tx.DeleteProjectStatus(ctx, orgID, projectID, id)
+		return err
+	})
+}
+
+func statusIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("status_id"), 10, 64)
+	if err != nil {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "invalid status id")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func validateStatusPayload(payload *CreateProjectStatusPayload) error {
+	var v Validator
+	v.String("name", payload.Name).Required().MaxLen(maxStatusNameLength)
+	v.String("category", string(payload.Category)).Required().OneOf(statusCategories...)
+	if payload.Position != nil {
+		v.Check("position", *payload.Position >= 0, "position can't be negative")
+	}
+
+	return v.Err()
+}
+
+// validateUpdateStatusPayload checks the fields the patch sets like those
+// of a new status. None of them can be removed by setting it to null.
+func validateUpdateStatusPayload(payload *UpdateProjectStatusPayload) error {
+	var v Validator
+	if payload.Name.Set && v.NotNull("name", payload.Name.Null) {
+		v.String("name", payload.Name.Value).Required().MaxLen(maxStatusNameLength)
+	}
+
+	if payload.Category.Set && v.NotNull("category", payload.Category.Null) {
+		v.String("category", string(payload.Category.Value)).Required().OneOf(statusCategories...)
+	}
+
+	if payload.Position.Set && v.NotNull("position", payload.Position.Null) {
+		v.Check("position", payload.Position.Value >= 0, "position can't be negative")
+	}
+
+	return v.Err()
+}
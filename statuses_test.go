@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestProjectStatuses(t *testing.T) {
+	newStore := func() *MockStore {
+		return &MockStore{
+			projects: []*Project{{ID: 1, OrgID: testOrgID}},
+			members: []*ProjectMember{
+				{ProjectID: 1, UserID: 1, Role: ProjectRoleOwner},
+				{ProjectID: 1, UserID: 3, Role: ProjectRoleContributor},
+			},
+			statuses: statusesOf(1),
+		}
+	}
+
+	routes := func(ms *MockStore) http.Handler {
+		router := http.NewServeMux()
+
+		service := NewProjectService(ms)
+		router.HandleFunc("GET /projects/{project_id}/statuses", service.HandleStatusList)
+		router.HandleFunc("POST /projects/{project_id}/statuses", service.HandleStatusCreate)
+		router.HandleFunc("GET /projects/{project_id}/statuses/{status_id}", service.HandleStatusGet)
+		router.HandleFunc("PATCH /projects/{project_id}/statuses/{status_id}", service.HandleStatusUpdate)
+		router.HandleFunc("DELETE /projects/{project_id}/statuses/{status_id}", service.HandleStatusDelete)
+
+		return router
+	}
+
+	t.Run("should list the statuses of the project", func(t *testing.T) {
+		rr := serveAs(t, routes(newStore()), 3, http.MethodGet, "/projects/1/statuses", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var statuses []ProjectStatusResponse
+		if err := json.NewDecoder(rr.Body).Decode(&statuses); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(statuses) != 4 || statuses[0].Name != "TODO" || statuses[3].Category != StatusCategoryDone {
+			t.Errorf("expected the default statuses, got %+v", statuses)
+		}
+	})
+
+	t.Run("should add a status after the last one", func(t *testing.T) {
+		ms := newStore()
+
+		rr := serveAs(t, routes(ms), 1, http.MethodPost, "/projects/1/statuses", `{"name": "BLOCKED", "category": "in_progress"}`)
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body)
+		}
+
+		var st ProjectStatusResponse
+		if err := json.NewDecoder(rr.Body).Decode(&st); err != nil {
+			t.Fatal(err)
+		}
+
+		if st.ID == 0 || st.ProjectID != 1 || st.Name != "BLOCKED" || st.Category != StatusCategoryInProgress || st.Position != 4 {
+			t.Errorf("unexpected status: %+v", st)
+		}
+	})
+
+	t.Run("should rename a status", func(t *testing.T) {
+		ms := newStore()
+
+		rr := serveAs(t, routes(ms), 1, http.MethodPatch, "/projects/1/statuses/13", `{"name": "IN_REVIEW"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if st := ms.statuses[2]; st.Name != "IN_REVIEW" || st.Category != StatusCategoryInProgress || st.Position != 2 {
+			t.Errorf("expected only the name to change, got %+v", st)
+		}
+	})
+
+	t.Run("should delete a status", func(t *testing.T) {
+		ms := newStore()
+
+		if rr := serveAs(t, routes(ms), 1, http.MethodDelete, "/projects/1/statuses/13", ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+
+		if len(ms.statuses) != 3 {
+			t.Errorf("expected 3 statuses left, got %+v", ms.statuses)
+		}
+	})
+
+	t.Run("should keep the last status", func(t *testing.T) {
+		ms := newStore()
+		ms.statuses = ms.statuses[:1]
+
+		if rr := serveAs(t, routes(ms), 1, http.MethodDelete, "/projects/1/statuses/11", ""); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+
+		if len(ms.statuses) != 1 {
+			t.Errorf("expected the status to be kept, got %+v", ms.statuses)
+		}
+	})
+
+	t.Run("should keep the last transitions of a configured workflow", func(t *testing.T) {
+		ms := newStore()
+		ms.workflows = map[int64][]WorkflowTransition{1: {{FromStatusID: 11, ToStatusID: 14}}}
+
+		// without them it would be the default workflow
+		if rr := serveAs(t, routes(ms), 1, http.MethodDelete, "/projects/1/statuses/14", ""); rr.Code != http.StatusConflict {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+
+		if rr := serveAs(t, routes(ms), 1, http.MethodDelete, "/projects/1/statuses/13", ""); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body)
+		}
+
+		if len(ms.statuses) != 3 || len(ms.workflows[1]) != 1 {
+			t.Errorf("expected only status 13 to go, got %+v and %+v", ms.statuses, ms.workflows[1])
+		}
+	})
+
+	t.Run("should keep statuses with tasks", func(t *testing.T) {
+		ms := newStore()
+		ms.errs = map[string]error{"DeleteProjectStatus": errStatusHasTasks}
+
+		if rr := serveAs(t, routes(ms), 1, http.MethodDelete, "/projects/1/statuses/11", ""); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		caller int64
+		method string
+		path   string
+		body   string
+		want   int
+		fields []string
+	}{
+		{
+			name:   "should validate a new status",
+			caller: 1,
+			method: http.MethodPost,
+			path:   "/projects/1/statuses",
+			body:   `{"name": "", "category": "blocked", "position": -1}`,
+			want:   http.StatusBadRequest,
+			fields: []string{"name", "category", "position"},
+		},
+		{
+			name:   "should validate every field of the patch",
+			caller: 1,
+			method: http.MethodPatch,
+			path:   "/projects/1/statuses/11",
+			body:   `{"name": null, "category": "blocked", "position": null}`,
+			want:   http.StatusBadRequest,
+			fields: []string{"name", "category", "position"},
+		},
+		{
+			name:   "should forbid contributors from adding statuses",
+			caller: 3,
+			method: http.MethodPost,
+			path:   "/projects/1/statuses",
+			body:   `{"name": "BLOCKED", "category": "in_progress"}`,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "should forbid contributors from deleting statuses",
+			caller: 3,
+			method: http.MethodDelete,
+			path:   "/projects/1/statuses/11",
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "should return 404 for an unknown status",
+			caller: 3,
+			method: http.MethodGet,
+			path:   "/projects/1/statuses/21",
+			want:   http.StatusNotFound,
+		},
+		{
+			name:   "should return 404 for deleting an unknown status",
+			caller: 1,
+			method: http.MethodDelete,
+			path:   "/projects/1/statuses/21",
+			want:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newStore()
+
+			rr := serveAs(t, routes(ms), tt.caller, tt.method, tt.path, tt.body)
+			if rr.Code != tt.want {
+				t.Fatalf("expected status code %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+
+			if tt.fields != nil {
+				var p Problem
+				if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+					t.Fatal(err)
+				}
+
+				fields := []string{}
+				for _, f := range p.Errors {
+					fields = append(fields, f.Field)
+				}
+
+				if !reflect.DeepEqual(fields, tt.fields) {
+					t.Errorf("expected errors for %v, got %+v", tt.fields, p.Errors)
+				}
+			}
+
+			if len(ms.statuses) != 4 || ms.statuses[0].Name != "TODO" {
+				t.Errorf("expected the statuses to stay as they were, got %+v", ms.statuses)
+			}
+		})
+	}
+}
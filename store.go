@@ -1,122 +1,1323 @@
-package main
-
-import (
-	"database/sql"
-)
-
-type Store interface {
-	// Users
-	CreateUser(u *User) (*User, error)
-	GetUserByID(id string) (*User, error)
-
-	// Tasks
-	CreateTask(t *Task) (*Task, error)
-	GetTask(id string) (*Task, error)
-
-	// Project
-	CreateProject(p *Project) (*Project, error)
-	GetProjectByID(id string) (*Project, error)
-	DeleteProject(id string) (int64, error)
-}
-
-type Storage struct {
-	db *sql.DB
-}
-
-func NewStore(db *sql.DB) *Storage {
-	return &Storage{
-		db: db,
-	}
-}
-
-func (s *Storage) CreateUser(u *User) (*User, error) {
-	rows, err := s.db.Exec("INSERT INTO users (email, first_name, last_name, password) VALUES (?, ?, ?, ?)",
-		u.Email, u.FirstName, u.LastName, u.Password)
-
-	if err != nil {
-		return nil, err
-	}
-
-	id, err := rows.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-
-	u.ID = id
-	return u, nil
-}
-
-func (s *Storage) GetUserByID(id string) (*User, error) {
-	var u User
-	err := s.db.QueryRow("SELECT id, email, first_name, last_name, created_at FROM users WHERE id = ?", id).Scan(
-		&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.CreatedAt,
-	)
-
-	return &u, err
-}
-
-func (s *Storage) CreateTask(t *Task) (*Task, error) {
-	rows, err := s.db.Exec("INSERT INTO tasks (name, project_id, assigned_to) VALUES (?, ?, ?)",
-		t.Name, t.ProjectID, t.AssignedTo)
-
-	if err != nil {
-		return nil, err
-	}
-
-	id, err := rows.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-
-	t.ID = id
-	return t, nil
-}
-
-func (s *Storage) GetTask(id string) (*Task, error) {
-	var t Task
-	err := s.db.QueryRow("SELECT id, name, status, project_id, assigned_to, created_at FROM tasks WHERE id = ?", id).Scan(
-		&t.ID, &t.Name, &t.Status, &t.ProjectID, &t.AssignedTo, &t.CreatedAt,
-	)
-	return &t, err
-}
-
-// CreateProject implements Store.
-func (s *Storage) CreateProject(p *Project) (*Project, error) {
-	rows, err := s.db.Exec("INSERT INTO projects (name) VALUES (?)", p.Name)
-	if err != nil {
-		return nil, err
-	}
-
-	id, err := rows.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-
-	p.ID = id
-	return p, nil
-}
-
-// DeleteProject implements Store.
-func (s *Storage) DeleteProject(id string) (int64, error) {
-	rows, err := s.db.Exec("DELETE FROM projects WHERE id = ?", id)
-	if err != nil {
-		return 0, err
-	}
-
-	rowsAffected, err := rows.RowsAffected()
-	if err != nil {
-		return 0, err
-	}
-
-	return rowsAffected, nil
-}
-
-// GetProjectByID implements Store.
-func (s *Storage) GetProjectByID(id string) (*Project, error) {
-	var p Project
-	err := s.db.QueryRow("SELECT id, name, created_at FROM projects WHERE id = ?", id).Scan(
-		&p.ID, &p.Name, &p.CreatedAt,
-	)
-
-	return &p, err
-}
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Store interface {
+	// Users
+	CreateUser(ctx context.Context, u *User) (*User, error)
+	GetUserByID(ctx context.Context, id string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdateUserRole(ctx context.Context, id string, role Role) (int64, error)
+
+	// Organizations
+	CreateOrganization(ctx context.Context, o *Organization) (*Organization, error)
+	ListUserOrganizations(ctx context.Context, userID int64) ([]*Organization, error)
+	AddOrganizationMember(ctx context.Context, m *OrganizationMember) (*OrganizationMember, error)
+	GetOrganizationMember(ctx context.Context, orgID, userID int64) (*OrganizationMember, error)
+	ListOrganizationMembers(ctx context.Context, orgID int64) ([]*OrganizationMember, error)
+
+	// Everything below is scoped to an organization: rows of other
+	// organizations behave as if they didn't exist.
+
+	// Tasks
+	CreateTask(ctx context.Context, orgID int64, t *Task) (*Task, error)
+	GetTask(ctx context.Context, orgID int64, id string) (*Task, error)
+	ListTasks(ctx context.Context, orgID int64, q TaskQuery) (*TaskPage, error)
+	UpdateTask(ctx context.Context, orgID int64, id string, u TaskUpdate) (*Task, error)
+
+	// Refresh tokens
+	CreateRefreshToken(ctx context.Context, rt *RefreshToken) (*RefreshToken, error)
+	GetRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	UseRefreshToken(ctx context.Context, id int64, at time.Time) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error
+
+	// Access token revocation
+	RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeUserTokens(ctx context.Context, userID int64, at time.Time) error
+	DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error)
+
+	// Project
+	CreateProject(ctx context.Context, orgID int64, p *Project) (*Project, error)
+	GetProjectByID(ctx context.Context, orgID int64, id string) (*Project, error)
+	ListProjects(ctx context.Context, orgID int64, q ProjectQuery) (*ProjectPage, error)
+	DeleteProject(ctx context.Context, orgID int64, id string) (int64, error)
+	// LockProject holds the project until the transaction it runs in ends,
+	// so transactions checking what the project has left before changing
+	// it take turns
+	LockProject(ctx context.Context, orgID, projectID int64) error
+
+	// Project members
+	AddProjectMember(ctx context.Context, orgID int64, m *ProjectMember) (*ProjectMember, error)
+	GetProjectMember(ctx context.Context, orgID, projectID, userID int64) (*ProjectMember, error)
+	ListProjectMembers(ctx context.Context, orgID, projectID int64) ([]*ProjectMember, error)
+	RemoveProjectMember(ctx context.Context, orgID, projectID, userID int64) (int64, error)
+
+	// Project statuses
+	CreateProjectStatus(ctx context.Context, orgID int64, st *ProjectStatus) (*ProjectStatus, error)
+	GetProjectStatus(ctx context.Context, orgID, projectID, id int64) (*ProjectStatus, error)
+	ListProjectStatuses(ctx context.Context, orgID, projectID int64) ([]*ProjectStatus, error)
+	UpdateProjectStatus(ctx context.Context, orgID, projectID, id int64, u ProjectStatusUpdate) (*ProjectStatus, error)
+	DeleteProjectStatus(ctx context.Context, orgID, projectID, id int64) (int64, error)
+
+	// Workflows
+	ListWorkflowTransitions(ctx context.Context, orgID, projectID int64) ([]WorkflowTransition, error)
+	ReplaceWorkflowTransitions(ctx context.Context, orgID, projectID int64, transitions []WorkflowTransition) error
+
+	// WithTx runs fn in a transaction: everything fn does through the Store
+	// it's given is committed when it returns nil and rolled back otherwise.
+	// fn may run more than once, see Storage.WithTx.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// SQLDialect is what differs between the databases Storage runs on. The
+// queries are written for MySQL, SQLite runs them as they are.
+type SQLDialect struct {
+	// Rebind rewrites the ? placeholders of a query, nil keeps them.
+	Rebind func(query string) string
+	// ReturningID reads the id of an inserted row with RETURNING id instead
+	// of LastInsertId, which Postgres doesn't support.
+	ReturningID bool
+	// IsDeadlock tells whether a transaction failed only because it lost a
+	// deadlock and is worth retrying.
+	IsDeadlock func(err error) bool
+	// ErrorKind tells which of ErrConflict, ErrForeignKey and ErrValidation
+	// a constraint violation of the driver is, nil for other errors.
+	ErrorKind func(err error) error
+	// Time converts a time compared with a column, nil passes it as it is.
+	Time func(t time.Time) any
+}
+
+var MySQLDialect = SQLDialect{
+	IsDeadlock: isMySQLDeadlock,
+	ErrorKind:  mysqlErrorKind,
+}
+
+var SQLiteDialect = SQLDialect{
+	IsDeadlock: isSQLiteBusy,
+	ErrorKind:  sqliteErrorKind,
+	Time:       sqliteTime,
+}
+
+var PostgresDialect = SQLDialect{
+	Rebind:      rebindDollar,
+	ReturningID: true,
+	IsDeadlock:  isPostgresDeadlock,
+	ErrorKind:   postgresErrorKind,
+}
+
+func (d SQLDialect) time(t time.Time) any {
+	if d.Time == nil {
+		return t
+	}
+
+	return d.Time(t)
+}
+
+func (d SQLDialect) rebind(query string) string {
+	if d.Rebind == nil {
+		return query
+	}
+
+	return d.Rebind(query)
+}
+
+// storeError turns err into the typed error Store returns for it. Errors
+// that aren't the caller's fault, e.g. a lost connection, stay as they are.
+func (d SQLDialect) storeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &StoreError{Kind: ErrNotFound, Err: err}
+	}
+
+	if d.ErrorKind != nil {
+		if kind := d.ErrorKind(err); kind != nil {
+			return &StoreError{Kind: kind, Err: err}
+		}
+	}
+
+	return err
+}
+
+// row is a *sql.Row whose Scan returns the Store's typed errors.
+type row struct {
+	*sql.Row
+	dialect SQLDialect
+}
+
+func (r row) Scan(dest ...any) error {
+	return r.dialect.storeError(r.Row.Scan(dest...))
+}
+
+// txAttempts is how often WithTx runs a transaction that keeps deadlocking.
+const txAttempts = 3
+
+// querier runs the queries of a Storage, on the database or in a transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type Storage struct {
+	db *sql.DB
+	// tx is set on the Storage WithTx hands to its callback
+	tx      *sql.Tx
+	dialect SQLDialect
+	// QueryTimeout bounds every Store call on top of the caller's context,
+	// 0 means no deadline.
+	QueryTimeout time.Duration
+}
+
+func NewStore(db *sql.DB) *Storage {
+	return NewStoreWithDialect(db, MySQLDialect)
+}
+
+func NewStoreWithDialect(db *sql.DB, dialect SQLDialect) *Storage {
+	return &Storage{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// withTimeout derives the context a Store call runs its queries with.
+func (s *Storage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, s.QueryTimeout)
+}
+
+func (s *Storage) querier() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+
+	return s.db
+}
+
+func (s *Storage) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := s.querier().ExecContext(ctx, s.dialect.rebind(query), args...)
+	return res, s.dialect.storeError(err)
+}
+
+func (s *Storage) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rows, err := s.querier().QueryContext(ctx, s.dialect.rebind(query), args...)
+	return rows, s.dialect.storeError(err)
+}
+
+func (s *Storage) queryRow(ctx context.Context, query string, args ...any) row {
+	return row{Row: s.querier().QueryRowContext(ctx, s.dialect.rebind(query), args...), dialect: s.dialect}
+}
+
+// WithTx implements Store. A transaction that loses a deadlock is rolled
+// back and fn runs again, up to txAttempts times, so fn shouldn't do
+// anything besides its queries that can't be repeated. WithTx on the Store
+// handed to fn joins the transaction already running. The per-query timeout
+// applies to every query fn runs, the transaction itself only ends with ctx.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, fn)
+		if err == nil || attempt == txAttempts || s.dialect.IsDeadlock == nil || !s.dialect.IsDeadlock(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *Storage) runTx(ctx context.Context, fn func(tx Store) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// a no-op once committed, rolls back when fn fails or panics
+	defer tx.Rollback()
+
+	txStore := *s
+	txStore.tx = tx
+	if err := fn(&txStore); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insert runs an INSERT and returns the id of the new row. An insert through
+// a select that matched nothing returns ErrNotFound.
+func (s *Storage) insert(ctx context.Context, query string, args ...any) (int64, error) {
+	var id int64
+	if s.dialect.ReturningID {
+		err := s.queryRow(ctx, query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	rows, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if n, err := rows.RowsAffected(); err != nil || n == 0 {
+		return 0, noRowsOr(err)
+	}
+
+	return rows.LastInsertId()
+}
+
+func (s *Storage) CreateUser(ctx context.Context, u *User) (*User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	id, err := s.insert(ctx, "INSERT INTO users (email, first_name, last_name, password, role) VALUES (?, ?, ?, ?, ?)",
+		u.Email, u.FirstName, u.LastName, u.Password, u.Role)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u.ID = id
+	return u, nil
+}
+
+func (s *Storage) GetUserByID(ctx context.Context, id string) (*User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if !isID(id) {
+		return nil, ErrNotFound
+	}
+
+	var u User
+	err := s.queryRow(ctx, "SELECT id, email, first_name, last_name, role, created_at, tokens_valid_after FROM users WHERE id = ?", id).Scan(
+		&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.Role, &u.CreatedAt, &u.TokensValidAfter,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+func (s *Storage) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var u User
+	err := s.queryRow(ctx, "SELECT id, email, first_name, last_name, password, role, created_at FROM users WHERE email = ?", email).Scan(
+		&u.ID, &u.Email, &u.FirstName, &u.LastName, &u.Password, &u.Role, &u.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// UpdateUserRole implements Store. It counts the users matching id rather
+// than the rows affected, which MySQL leaves at 0 when the user already has
+// the role.
+func (s *Storage) UpdateUserRole(ctx context.Context, id string, role Role) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if !isID(id) {
+		return 0, nil
+	}
+
+	var n int64
+	if err := s.queryRow(ctx, "SELECT COUNT(*) FROM users WHERE id = ?", id).Scan(&n); err != nil {
+		return 0, err
+	}
+
+	if n == 0 {
+		return 0, nil
+	}
+
+	if _, err := s.exec(ctx, "UPDATE users SET role = ? WHERE id = ?", role, id); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// CreateTask inserts through a select on projects, so nothing is inserted
+// when the project belongs to another organization. The task starts in the
+// first status of its project.
+func (s *Storage) CreateTask(ctx context.Context, orgID int64, t *Task) (*Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	id, err := s.insert(ctx, `INSERT INTO tasks (name, project_id, status_id, assigned_to, created_by)
+		SELECT ?, p.id, ps.id, ?, ? FROM projects p JOIN project_statuses ps ON ps.project_id = p.id
+		WHERE p.id = ? AND p.org_id = ? ORDER BY ps.position, ps.id LIMIT 1`,
+		t.Name, t.AssignedTo, t.CreatedBy, t.ProjectID, orgID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTask(ctx, orgID, strconv.FormatInt(id, 10))
+}
+
+func (s *Storage) GetTask(ctx context.Context, orgID int64, id string) (*Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if !isID(id) {
+		return nil, ErrNotFound
+	}
+
+	var t Task
+	err := s.queryRow(ctx, `SELECT t.id, t.name, t.status_id, ps.name, ps.category, t.project_id, t.assigned_to, t.created_by, t.created_at
+		FROM tasks t JOIN projects p ON p.id = t.project_id JOIN project_statuses ps ON ps.id = t.status_id
+		WHERE t.id = ? AND p.org_id = ?`, id, orgID).Scan(
+		&t.ID, &t.Name, &t.StatusID, &t.Status, &t.StatusCategory, &t.ProjectID, &t.AssignedTo, &t.CreatedBy, &t.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// UpdateTask implements Store. Moving a task to a project of another
+// organization fails with ErrForeignKey, like moving it to one that doesn't
+// exist, and so does leaving the task in a status of another project.
+// Tasks never leave their organization, so once it is found there it can be
+// updated by id, and by status with u.FromStatusID.
+func (s *Storage) UpdateTask(ctx context.Context, orgID int64, id string, u TaskUpdate) (*Task, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	t, err := s.GetTask(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	var args []any
+	if u.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *u.Name)
+	}
+
+	if u.StatusID != nil {
+		sets = append(sets, "status_id = ?")
+		args = append(args, *u.StatusID)
+	}
+
+	if u.AssignedTo != nil {
+		sets = append(sets, "assigned_to = ?")
+		args = append(args, *u.AssignedTo)
+	}
+
+	if u.ProjectID != nil {
+		sets = append(sets, "project_id = ?")
+		args = append(args, *u.ProjectID)
+	}
+
+	if u.StatusID != nil || u.ProjectID != nil {
+		projectID, statusID := t.ProjectID, t.StatusID
+		if u.ProjectID != nil {
+			projectID = *u.ProjectID
+		}
+
+		if u.StatusID != nil {
+			statusID = *u.StatusID
+		}
+
+		var n int
+		if err := s.queryRow(ctx, `SELECT COUNT(*) FROM project_statuses ps JOIN projects p ON p.id = ps.project_id
+			WHERE ps.id = ? AND p.id = ? AND p.org_id = ?`, statusID, projectID, orgID).Scan(&n); err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			return nil, ErrForeignKey
+		}
+	}
+
+	where := " WHERE id = ?"
+	args = append(args, id)
+	// the status changes, so a row matching it is always affected
+	conditional := u.StatusID != nil && u.FromStatusID != nil && *u.StatusID != *u.FromStatusID
+	if conditional {
+		where += " AND status_id = ?"
+		args = append(args, *u.FromStatusID)
+	}
+
+	if len(sets) > 0 {
+		rows, err := s.exec(ctx, "UPDATE tasks SET "+strings.Join(sets, ", ")+where, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		if n, err := rows.RowsAffected(); conditional && (err != nil || n == 0) {
+			return nil, taskStatusChangedOr(err)
+		}
+	}
+
+	return s.GetTask(ctx, orgID, id)
+}
+
+// ListTasks implements Store. Pages are read by keyset like ListProjects
+// does, the filters of the project, assignee and status are covered by the
+// indexes of the tasks table. Statuses are matched by name, so one filter
+// covers the statuses of the same name in different projects.
+func (s *Storage) ListTasks(ctx context.Context, orgID int64, q TaskQuery) (*TaskPage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	where := "p.org_id = ?"
+	args := []any{orgID}
+	if q.ProjectID != 0 {
+		where += " AND t.project_id = ?"
+		args = append(args, q.ProjectID)
+	}
+
+	if q.MemberID != 0 {
+		where += " AND EXISTS (SELECT 1 FROM project_members pm WHERE pm.project_id = t.project_id AND pm.user_id = ?)"
+		args = append(args, q.MemberID)
+	}
+
+	if q.AssignedTo != 0 {
+		where += " AND t.assigned_to = ?"
+		args = append(args, q.AssignedTo)
+	}
+
+	if len(q.Statuses) > 0 {
+		where += " AND ps.name IN (?" + strings.Repeat(", ?", len(q.Statuses)-1) + ")"
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+
+	if len(q.Categories) > 0 {
+		where += " AND ps.category IN (?" + strings.Repeat(", ?", len(q.Categories)-1) + ")"
+		for _, category := range q.Categories {
+			args = append(args, category)
+		}
+	}
+
+	if !q.CreatedAfter.IsZero() {
+		where += " AND t.created_at >= ?"
+		args = append(args, s.dialect.time(q.CreatedAfter))
+	}
+
+	if !q.CreatedBefore.IsZero() {
+		where += " AND t.created_at < ?"
+		args = append(args, s.dialect.time(q.CreatedBefore))
+	}
+
+	const from = " FROM tasks t JOIN projects p ON p.id = t.project_id JOIN project_statuses ps ON ps.id = t.status_id WHERE "
+
+	page := &TaskPage{Tasks: []*Task{}}
+	if q.CountTotal {
+		if err := s.queryRow(ctx, "SELECT COUNT(*)"+from+where, args...).Scan(&page.Total); err != nil {
+			return nil, err
+		}
+	}
+
+	column, op, order := "t.created_at", ">", "ASC"
+	if q.Sort == TaskSortName {
+		column = "t.name"
+	}
+
+	if q.Desc {
+		op, order = "<", "DESC"
+	}
+
+	if q.After != nil {
+		var key any = s.dialect.time(q.After.CreatedAt)
+		if q.Sort == TaskSortName {
+			key = q.After.Name
+		}
+
+		where += " AND (" + column + " " + op + " ? OR (" + column + " = ? AND t.id " + op + " ?))"
+		args = append(args, key, key, q.After.ID)
+	}
+
+	rows, err := s.query(ctx, "SELECT t.id, t.name, t.status_id, ps.name, ps.category, t.project_id, t.assigned_to, t.created_by, t.created_at"+from+where+
+		" ORDER BY "+column+" "+order+", t.id "+order+" LIMIT ?", append(args, q.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t Task
+		if err := rows.Scan(&t.ID, &t.Name, &t.StatusID, &t.Status, &t.StatusCategory, &t.ProjectID, &t.AssignedTo, &t.CreatedBy, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		page.Tasks = append(page.Tasks, &t)
+	}
+
+	if len(page.Tasks) > q.Limit {
+		page.Tasks, page.More = page.Tasks[:q.Limit], true
+	}
+
+	return page, rows.Err()
+}
+
+// CreateRefreshToken implements Store.
+func (s *Storage) CreateRefreshToken(ctx context.Context, rt *RefreshToken) (*RefreshToken, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	id, err := s.insert(ctx, "INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES (?, ?, ?, ?)",
+		rt.UserID, rt.FamilyID, rt.TokenHash, rt.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rt.ID = id
+	return rt, nil
+}
+
+// GetRefreshTokenByHash implements Store.
+func (s *Storage) GetRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var rt RefreshToken
+	err := s.queryRow(ctx, "SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at FROM refresh_tokens WHERE token_hash = ?", hash).Scan(
+		&rt.ID, &rt.UserID, &rt.FamilyID, &rt.TokenHash, &rt.ExpiresAt, &rt.UsedAt, &rt.RevokedAt, &rt.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rt, nil
+}
+
+// UseRefreshToken implements Store. It reports false when the token was
+// already used or revoked, so two concurrent refreshes can't both succeed.
+func (s *Storage) UseRefreshToken(ctx context.Context, id int64, at time.Time) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.exec(ctx, "UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL", at, id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// RevokeRefreshTokenFamily implements Store.
+func (s *Storage) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL", at, familyID)
+	return err
+}
+
+// RevokeToken implements Store. The entry is kept until the token would
+// have expired anyway, after which DeleteExpiredRevokedTokens drops it.
+func (s *Storage) RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, "INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES (?, ?, ?)", jti, userID, expiresAt)
+	return err
+}
+
+// IsTokenRevoked implements Store.
+func (s *Storage) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var n int
+	err := s.queryRow(ctx, "SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?", jti).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// RevokeUserTokens implements Store. Access tokens are cut off by issue
+// time, refresh tokens are revoked outright.
+func (s *Storage) RevokeUserTokens(ctx context.Context, userID int64, at time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.exec(ctx, "UPDATE users SET tokens_valid_after = ? WHERE id = ?", at, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(ctx, "UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", at, userID)
+	return err
+}
+
+// DeleteExpiredRevokedTokens implements Store.
+func (s *Storage) DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.exec(ctx, "DELETE FROM revoked_tokens WHERE expires_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// CreateProject implements Store. The project is inserted with its
+// defaultStatuses in one transaction, joining the one already running if
+// there is one.
+func (s *Storage) CreateProject(ctx context.Context, orgID int64, p *Project) (*Project, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	p.OrgID = orgID
+	err := s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*Storage)
+
+		id, err := s.insert(ctx, "INSERT INTO projects (org_id, name, created_by) VALUES (?, ?, ?)", p.OrgID, p.Name, p.CreatedBy)
+		if err != nil {
+			return err
+		}
+
+		for _, st := range defaultStatuses {
+			if _, err := s.insert(ctx, "INSERT INTO project_statuses (project_id, name, category, position) VALUES (?, ?, ?, ?)",
+				id, st.Name, st.Category, st.Position); err != nil {
+				return err
+			}
+		}
+
+		p.ID = id
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// DeleteProject implements Store. The tasks of the project go with it, its
+// members, statuses and workflow cascade.
+func (s *Storage) DeleteProject(ctx context.Context, orgID int64, id string) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if !isID(id) {
+		return 0, nil
+	}
+
+	var rowsAffected int64
+	err := s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*Storage)
+
+		var n int
+		if err := s.queryRow(ctx, "SELECT COUNT(*) FROM projects WHERE id = ? AND org_id = ?", id, orgID).Scan(&n); err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return nil
+		}
+
+		if _, err := s.exec(ctx, "DELETE FROM tasks WHERE project_id = ?", id); err != nil {
+			return err
+		}
+
+		rows, err := s.exec(ctx, "DELETE FROM projects WHERE id = ? AND org_id = ?", id, orgID)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err = rows.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// LockProject implements Store. Updating the row locks it on every
+// database, a plain SELECT wouldn't under MySQL's REPEATABLE READ.
+func (s *Storage) LockProject(ctx context.Context, orgID, projectID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.exec(ctx, "UPDATE projects SET name = name WHERE id = ? AND org_id = ?", projectID, orgID); err != nil {
+		return err
+	}
+
+	// MySQL doesn't count the unchanged row as affected
+	var n int
+	if err := s.queryRow(ctx, "SELECT COUNT(*) FROM projects WHERE id = ? AND org_id = ?", projectID, orgID).Scan(&n); err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetProjectByID implements Store.
+func (s *Storage) GetProjectByID(ctx context.Context, orgID int64, id string) (*Project, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if !isID(id) {
+		return nil, ErrNotFound
+	}
+
+	var p Project
+	err := s.queryRow(ctx, "SELECT id, org_id, name, created_by, created_at FROM projects WHERE id = ? AND org_id = ?", id, orgID).Scan(
+		&p.ID, &p.OrgID, &p.Name, &p.CreatedBy, &p.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// ListProjects implements Store. Pages are read by keyset: ordered by the
+// sort column and then id, a page starts right after the position of the
+// last project of the previous one.
+func (s *Storage) ListProjects(ctx context.Context, orgID int64, q ProjectQuery) (*ProjectPage, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	where := "p.org_id = ?"
+	args := []any{orgID}
+	if q.MemberID != 0 {
+		where += " AND EXISTS (SELECT 1 FROM project_members pm WHERE pm.project_id = p.id AND pm.user_id = ?)"
+		args = append(args, q.MemberID)
+	}
+
+	if q.NamePrefix != "" {
+		where += " AND LOWER(p.name) LIKE ? ESCAPE '!'"
+		args = append(args, escapeLike(strings.ToLower(q.NamePrefix))+"%")
+	}
+
+	if q.Search != "" {
+		where += " AND LOWER(p.name) LIKE ? ESCAPE '!'"
+		args = append(args, "%"+escapeLike(strings.ToLower(q.Search))+"%")
+	}
+
+	page := &ProjectPage{Projects: []*Project{}}
+	if q.CountTotal {
+		if err := s.queryRow(ctx, "SELECT COUNT(*) FROM projects p WHERE "+where, args...).Scan(&page.Total); err != nil {
+			return nil, err
+		}
+	}
+
+	column, op, order := "p.created_at", ">", "ASC"
+	if q.Sort == ProjectSortName {
+		column = "p.name"
+	}
+
+	if q.Desc {
+		op, order = "<", "DESC"
+	}
+
+	if q.After != nil {
+		var key any = s.dialect.time(q.After.CreatedAt)
+		if q.Sort == ProjectSortName {
+			key = q.After.Name
+		}
+
+		where += " AND (" + column + " " + op + " ? OR (" + column + " = ? AND p.id " + op + " ?))"
+		args = append(args, key, key, q.After.ID)
+	}
+
+	// one more than asked for tells whether there is a next page
+	rows, err := s.query(ctx, "SELECT p.id, p.org_id, p.name, p.created_by, p.created_at FROM projects p WHERE "+where+
+		" ORDER BY "+column+" "+order+", p.id "+order+" LIMIT ?", append(args, q.Limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Project
+		if err := rows.Scan(&p.ID, &p.OrgID, &p.Name, &p.CreatedBy, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		page.Projects = append(page.Projects, &p)
+	}
+
+	if len(page.Projects) > q.Limit {
+		page.Projects, page.More = page.Projects[:q.Limit], true
+	}
+
+	return page, rows.Err()
+}
+
+// AddProjectMember implements Store.
+func (s *Storage) AddProjectMember(ctx context.Context, orgID int64, m *ProjectMember) (*ProjectMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	m.CreatedAt = time.Now()
+	rows, err := s.exec(ctx, "INSERT INTO project_members (project_id, user_id, role, created_at) SELECT id, ?, ?, ? FROM projects WHERE id = ? AND org_id = ?",
+		m.UserID, m.Role, m.CreatedAt, m.ProjectID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, err := rows.RowsAffected(); err != nil || n == 0 {
+		return nil, noRowsOr(err)
+	}
+
+	return m, nil
+}
+
+// GetProjectMember implements Store.
+func (s *Storage) GetProjectMember(ctx context.Context, orgID, projectID, userID int64) (*ProjectMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var m ProjectMember
+	err := s.queryRow(ctx, `SELECT pm.project_id, pm.user_id, pm.role, pm.created_at
+		FROM project_members pm JOIN projects p ON p.id = pm.project_id
+		WHERE pm.project_id = ? AND pm.user_id = ? AND p.org_id = ?`, projectID, userID, orgID).Scan(
+		&m.ProjectID, &m.UserID, &m.Role, &m.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// ListProjectMembers implements Store.
+func (s *Storage) ListProjectMembers(ctx context.Context, orgID, projectID int64) ([]*ProjectMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, `SELECT pm.project_id, pm.user_id, pm.role, pm.created_at
+		FROM project_members pm JOIN projects p ON p.id = pm.project_id
+		WHERE pm.project_id = ? AND p.org_id = ?
+		ORDER BY pm.created_at, pm.user_id`, projectID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*ProjectMember{}
+	for rows.Next() {
+		var m ProjectMember
+		if err := rows.Scan(&m.ProjectID, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, &m)
+	}
+
+	return members, rows.Err()
+}
+
+// RemoveProjectMember implements Store.
+func (s *Storage) RemoveProjectMember(ctx context.Context, orgID, projectID, userID int64) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.exec(ctx, "DELETE FROM project_members WHERE project_id = ? AND user_id = ? AND project_id IN (SELECT id FROM projects WHERE org_id = ?)",
+		projectID, userID, orgID)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// CreateProjectStatus implements Store. It inserts through a select on
+// projects like AddProjectMember does.
+func (s *Storage) CreateProjectStatus(ctx context.Context, orgID int64, st *ProjectStatus) (*ProjectStatus, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	st.CreatedAt = time.Now()
+	id, err := s.insert(ctx, "INSERT INTO project_statuses (project_id, name, category, position, created_at) SELECT id, ?, ?, ?, ? FROM projects WHERE id = ? AND org_id = ?",
+		st.Name, st.Category, st.Position, st.CreatedAt, st.ProjectID, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	st.ID = id
+	return st, nil
+}
+
+// GetProjectStatus implements Store.
+func (s *Storage) GetProjectStatus(ctx context.Context, orgID, projectID, id int64) (*ProjectStatus, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var st ProjectStatus
+	err := s.queryRow(ctx, `SELECT ps.id, ps.project_id, ps.name, ps.category, ps.position, ps.created_at
+		FROM project_statuses ps JOIN projects p ON p.id = ps.project_id
+		WHERE ps.id = ? AND ps.project_id = ? AND p.org_id = ?`, id, projectID, orgID).Scan(
+		&st.ID, &st.ProjectID, &st.Name, &st.Category, &st.Position, &st.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &st, nil
+}
+
+// ListProjectStatuses implements Store.
+func (s *Storage) ListProjectStatuses(ctx context.Context, orgID, projectID int64) ([]*ProjectStatus, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, `SELECT ps.id, ps.project_id, ps.name, ps.category, ps.position, ps.created_at
+		FROM project_statuses ps JOIN projects p ON p.id = ps.project_id
+		WHERE ps.project_id = ? AND p.org_id = ?
+		ORDER BY ps.position, ps.id`, projectID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := []*ProjectStatus{}
+	for rows.Next() {
+		var st ProjectStatus
+		if err := rows.Scan(&st.ID, &st.ProjectID, &st.Name, &st.Category, &st.Position, &st.CreatedAt); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, &st)
+	}
+
+	return statuses, rows.Err()
+}
+
+// UpdateProjectStatus implements Store. Like UpdateTask, once the status is
+// found in the organization it can be updated by id.
+func (s *Storage) UpdateProjectStatus(ctx context.Context, orgID, projectID, id int64, u ProjectStatusUpdate) (*ProjectStatus, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.GetProjectStatus(ctx, orgID, projectID, id); err != nil {
+		return nil, err
+	}
+
+	var sets []string
+	var args []any
+	if u.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *u.Name)
+	}
+
+	if u.Category != nil {
+		sets = append(sets, "category = ?")
+		args = append(args, *u.Category)
+	}
+
+	if u.Position != nil {
+		sets = append(sets, "position = ?")
+		args = append(args, *u.Position)
+	}
+
+	if len(sets) > 0 {
+		if _, err := s.exec(ctx, "UPDATE project_statuses SET "+strings.Join(sets, ", ")+" WHERE id = ?", append(args, id)...); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetProjectStatus(ctx, orgID, projectID, id)
+}
+
+// DeleteProjectStatus implements Store. The transitions from and to the
+// status are deleted with it, a status tasks are in isn't.
+func (s *Storage) DeleteProjectStatus(ctx context.Context, orgID, projectID, id int64) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.GetProjectStatus(ctx, orgID, projectID, id); errors.Is(err, ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	rows, err := s.exec(ctx, "DELETE FROM project_statuses WHERE id = ?", id)
+	if errors.Is(err, ErrForeignKey) {
+		return 0, &StoreError{Kind: ErrConflict, Message: errStatusHasTasks.Message, Err: err}
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := rows.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, nil
+}
+
+// ListWorkflowTransitions implements Store.
+func (s *Storage) ListWorkflowTransitions(ctx context.Context, orgID, projectID int64) ([]WorkflowTransition, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, `SELECT st.from_status_id, st.to_status_id, st.guard
+		FROM status_transitions st JOIN project_statuses ps ON ps.id = st.from_status_id JOIN projects p ON p.id = ps.project_id
+		WHERE ps.project_id = ? AND p.org_id = ?`, projectID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []WorkflowTransition{}
+	for rows.Next() {
+		var tr WorkflowTransition
+		var guard sql.NullString
+		if err := rows.Scan(&tr.FromStatusID, &tr.ToStatusID, &guard); err != nil {
+			return nil, err
+		}
+		tr.Guard = TransitionGuard(guard.String)
+		transitions = append(transitions, tr)
+	}
+
+	return transitions, rows.Err()
+}
+
+// ReplaceWorkflowTransitions implements Store. The old transitions are
+// deleted and the new ones inserted in one transaction, joining the one
+// already running if there is one. A transition from or to a status of
+// another project fails with ErrForeignKey.
+func (s *Storage) ReplaceWorkflowTransitions(ctx context.Context, orgID, projectID int64, transitions []WorkflowTransition) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.WithTx(ctx, func(tx Store) error {
+		s := tx.(*Storage)
+
+		var n int
+		if err := s.queryRow(ctx, "SELECT COUNT(*) FROM projects WHERE id = ? AND org_id = ?", projectID, orgID).Scan(&n); err != nil {
+			return err
+		}
+
+		if n == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := s.exec(ctx, "DELETE FROM status_transitions WHERE from_status_id IN (SELECT id FROM project_statuses WHERE project_id = ?)", projectID); err != nil {
+			return err
+		}
+
+		for _, tr := range transitions {
+			if err := s.queryRow(ctx, "SELECT COUNT(*) FROM project_statuses WHERE project_id = ? AND id IN (?, ?)",
+				projectID, tr.FromStatusID, tr.ToStatusID).Scan(&n); err != nil {
+				return err
+			}
+
+			if n != 2 {
+				return ErrForeignKey
+			}
+
+			var guard any
+			if tr.Guard != "" {
+				guard = tr.Guard
+			}
+
+			if _, err := s.exec(ctx, "INSERT INTO status_transitions (from_status_id, to_status_id, guard) VALUES (?, ?, ?)",
+				tr.FromStatusID, tr.ToStatusID, guard); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CreateOrganization implements Store.
+func (s *Storage) CreateOrganization(ctx context.Context, o *Organization) (*Organization, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	id, err := s.insert(ctx, "INSERT INTO organizations (name, created_by) VALUES (?, ?)", o.Name, o.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	o.ID = id
+	return o, nil
+}
+
+// ListUserOrganizations implements Store.
+func (s *Storage) ListUserOrganizations(ctx context.Context, userID int64) ([]*Organization, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, `SELECT o.id, o.name, o.created_by, o.created_at
+		FROM organizations o JOIN organization_members om ON om.org_id = o.id
+		WHERE om.user_id = ?
+		ORDER BY o.id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []*Organization{}
+	for rows.Next() {
+		var o Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedBy, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, &o)
+	}
+
+	return orgs, rows.Err()
+}
+
+// AddOrganizationMember implements Store.
+func (s *Storage) AddOrganizationMember(ctx context.Context, m *OrganizationMember) (*OrganizationMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	m.CreatedAt = time.Now()
+	_, err := s.exec(ctx, "INSERT INTO organization_members (org_id, user_id, role, created_at) VALUES (?, ?, ?, ?)",
+		m.OrgID, m.UserID, m.Role, m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// GetOrganizationMember implements Store.
+func (s *Storage) GetOrganizationMember(ctx context.Context, orgID, userID int64) (*OrganizationMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var m OrganizationMember
+	err := s.queryRow(ctx, "SELECT org_id, user_id, role, created_at FROM organization_members WHERE org_id = ? AND user_id = ?", orgID, userID).Scan(
+		&m.OrgID, &m.UserID, &m.Role, &m.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// ListOrganizationMembers implements Store.
+func (s *Storage) ListOrganizationMembers(ctx context.Context, orgID int64) ([]*OrganizationMember, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.query(ctx, "SELECT org_id, user_id, role, created_at FROM organization_members WHERE org_id = ? ORDER BY created_at, user_id", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*OrganizationMember{}
+	for rows.Next() {
+		var m OrganizationMember
+		if err := rows.Scan(&m.OrgID, &m.UserID, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, &m)
+	}
+
+	return members, rows.Err()
+}
+
+// noRowsOr turns an insert-through-select that matched nothing into
+// ErrNotFound, the same error a scoped read would return.
+func noRowsOr(err error) error {
+	if err != nil {
+		return err
+	}
+
+	return ErrNotFound
+}
+
+// taskStatusChangedOr turns a conditional task update that matched nothing
+// into errTaskStatusChanged.
+func taskStatusChangedOr(err error) error {
+	if err != nil {
+		return err
+	}
+
+	return errTaskStatusChanged
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern with !, the escape
+// character every dialect takes the same way. MySQL reads a backslash in a
+// string literal as an escape of its own.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+// isID reports whether id, taken from a URL, can be an id at all. MySQL and
+// SQLite compare anything else as no match, Postgres fails the query.
+func isID(id string) bool {
+	_, err := strconv.ParseInt(id, 10, 64)
+	return err == nil
+}
+
+// rebindDollar numbers the ? placeholders of query as $1, $2, ... for
+// Postgres. None of our queries has a ? inside a string literal.
+func rebindDollar(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteString("$" + strconv.Itoa(n))
+	}
+
+	return b.String()
+}
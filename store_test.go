@@ -1,33 +1,723 @@
-package main
-
-// Mocks
-
-type MockStore struct{}
-
-func (m *MockStore) CreateUser(u *User) (*User, error) {
-	return &User{}, nil
-}
-
-func (m *MockStore) CreateTask(t *Task) (*Task, error) {
-	return &Task{}, nil
-}
-
-func (m *MockStore) GetTask(id string) (*Task, error) {
-	return &Task{}, nil
-}
-
-func (m *MockStore) GetUserByID(id string) (*User, error) {
-	return &User{}, nil
-}
-
-func (m *MockStore) CreateProject(u *Project) (*Project, error) {
-	return &Project{}, nil
-}
-
-func (m *MockStore) GetProjectByID(id string) (*Project, error) {
-	return &Project{}, nil
-}
-
-func (m *MockStore) DeleteProject(id string) (int64, error) {
-	return 0, nil
-}
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Helpers
+
+// testOrgID is the organization authenticate puts requests in.
+const testOrgID = 1
+
+// authenticate returns r as WithJWTAuth would hand it to a handler after
+// authenticating u as a member of testOrgID.
+func authenticate(r *http.Request, u *User) *http.Request {
+	return authenticateIn(r, u, testOrgID)
+}
+
+func authenticateIn(r *http.Request, u *User, orgID int64) *http.Request {
+	claims := newClaims(u.ID, "jti", time.Now())
+	claims.OrgID = orgID
+	return r.WithContext(WithOrg(WithAuth(r.Context(), u, claims), orgID))
+}
+
+// newJSONRequest returns a request with payload as its body, a string as it
+// is and anything else encoded as JSON. PATCH bodies are merge patches.
+func newJSONRequest(t *testing.T, method, path string, payload any) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	switch p := payload.(type) {
+	case nil:
+	case string:
+		body.WriteString(p)
+	default:
+		if err := json.NewEncoder(&body).Encode(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, path, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+
+	return req
+}
+
+// serveAs sends h a request with payload from caller, a member of testOrgID.
+func serveAs(t *testing.T, h http.Handler, caller int64, method, path string, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveAsUser(t, h, &User{ID: caller, Role: RoleMember}, method, path, payload)
+}
+
+// serveAsUser is serveAs for a caller of any role.
+func serveAsUser(t *testing.T, h http.Handler, u *User, method, path string, payload any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := authenticate(newJSONRequest(t, method, path, payload), u)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+// Mocks
+
+type MockStore struct {
+	users         []*User
+	refreshTokens []*RefreshToken
+	revoked       map[string]time.Time
+	projects      []*Project
+	tasks         []*Task
+	members       []*ProjectMember
+	orgs          []*Organization
+	orgMembers    []*OrganizationMember
+	statuses      []*ProjectStatus
+	workflows     map[int64][]WorkflowTransition
+	// errs makes the method of that name fail with the error
+	errs map[string]error
+}
+
+func (m *MockStore) CreateUser(ctx context.Context, u *User) (*User, error) {
+	if err := m.errs["CreateUser"]; err != nil {
+		return nil, err
+	}
+
+	u.ID = int64(len(m.users) + 1)
+	m.users = append(m.users, u)
+	return u, nil
+}
+
+func (m *MockStore) CreateTask(ctx context.Context, orgID int64, t *Task) (*Task, error) {
+	if err := m.errs["CreateTask"]; err != nil {
+		return nil, err
+	}
+
+	if !m.projectInOrg(orgID, t.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	if statuses, _ := m.ListProjectStatuses(ctx, orgID, t.ProjectID); len(statuses) > 0 {
+		t.StatusID, t.Status, t.StatusCategory = statuses[0].ID, statuses[0].Name, statuses[0].Category
+	}
+
+	m.tasks = append(m.tasks, t)
+	return t, nil
+}
+
+func (m *MockStore) GetTask(ctx context.Context, orgID int64, id string) (*Task, error) {
+	if err := m.errs["GetTask"]; err != nil {
+		return nil, err
+	}
+
+	for _, t := range m.tasks {
+		if strconv.FormatInt(t.ID, 10) == id && m.projectInOrg(orgID, t.ProjectID) {
+			return t, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) UpdateTask(ctx context.Context, orgID int64, id string, u TaskUpdate) (*Task, error) {
+	if err := m.errs["UpdateTask"]; err != nil {
+		return nil, err
+	}
+
+	t, err := m.GetTask(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.ProjectID != nil && !m.projectInOrg(orgID, *u.ProjectID) {
+		return nil, ErrForeignKey
+	}
+
+	var status *ProjectStatus
+	if u.StatusID != nil {
+		projectID := t.ProjectID
+		if u.ProjectID != nil {
+			projectID = *u.ProjectID
+		}
+
+		if status, err = m.GetProjectStatus(ctx, orgID, projectID, *u.StatusID); err != nil {
+			return nil, ErrForeignKey
+		}
+	}
+
+	if u.StatusID != nil && u.FromStatusID != nil && *u.StatusID != *u.FromStatusID && t.StatusID != *u.FromStatusID {
+		return nil, errTaskStatusChanged
+	}
+
+	if u.Name != nil {
+		t.Name = *u.Name
+	}
+
+	if status != nil {
+		t.StatusID, t.Status, t.StatusCategory = status.ID, status.Name, status.Category
+	}
+
+	if u.AssignedTo != nil {
+		t.AssignedTo = *u.AssignedTo
+	}
+
+	if u.ProjectID != nil {
+		t.ProjectID = *u.ProjectID
+	}
+
+	return t, nil
+}
+
+func (m *MockStore) ListTasks(ctx context.Context, orgID int64, q TaskQuery) (*TaskPage, error) {
+	if err := m.errs["ListTasks"]; err != nil {
+		return nil, err
+	}
+
+	tasks := []*Task{}
+	for _, t := range m.tasks {
+		if !m.projectInOrg(orgID, t.ProjectID) {
+			continue
+		}
+
+		if _, err := m.GetProjectMember(ctx, orgID, t.ProjectID, q.MemberID); q.MemberID != 0 && err != nil {
+			continue
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return pageTasks(tasks, q), nil
+}
+
+func (m *MockStore) GetUserByID(ctx context.Context, id string) (*User, error) {
+	if err := m.errs["GetUserByID"]; err != nil {
+		return nil, err
+	}
+
+	for _, u := range m.users {
+		if strconv.FormatInt(u.ID, 10) == id {
+			return u, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if err := m.errs["GetUserByEmail"]; err != nil {
+		return nil, err
+	}
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) UpdateUserRole(ctx context.Context, id string, role Role) (int64, error) {
+	if err := m.errs["UpdateUserRole"]; err != nil {
+		return 0, err
+	}
+
+	for _, u := range m.users {
+		if strconv.FormatInt(u.ID, 10) == id {
+			u.Role = role
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (m *MockStore) CreateRefreshToken(ctx context.Context, rt *RefreshToken) (*RefreshToken, error) {
+	if err := m.errs["CreateRefreshToken"]; err != nil {
+		return nil, err
+	}
+
+	rt.ID = int64(len(m.refreshTokens) + 1)
+	m.refreshTokens = append(m.refreshTokens, rt)
+	return rt, nil
+}
+
+func (m *MockStore) GetRefreshTokenByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	if err := m.errs["GetRefreshTokenByHash"]; err != nil {
+		return nil, err
+	}
+
+	for _, rt := range m.refreshTokens {
+		if rt.TokenHash == hash {
+			return rt, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) UseRefreshToken(ctx context.Context, id int64, at time.Time) (bool, error) {
+	if err := m.errs["UseRefreshToken"]; err != nil {
+		return false, err
+	}
+
+	for i, rt := range m.refreshTokens {
+		if rt.ID == id && rt.UsedAt == nil && rt.RevokedAt == nil {
+			// new rows in a new slice, WithTx restores the old ones
+			used := *rt
+			used.UsedAt = &at
+			m.refreshTokens = slices.Clone(m.refreshTokens)
+			m.refreshTokens[i] = &used
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *MockStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string, at time.Time) error {
+	if err := m.errs["RevokeRefreshTokenFamily"]; err != nil {
+		return err
+	}
+
+	for _, rt := range m.refreshTokens {
+		if rt.FamilyID == familyID && rt.RevokedAt == nil {
+			rt.RevokedAt = &at
+		}
+	}
+
+	return nil
+}
+
+func (m *MockStore) RevokeToken(ctx context.Context, jti string, userID int64, expiresAt time.Time) error {
+	if err := m.errs["RevokeToken"]; err != nil {
+		return err
+	}
+
+	if m.revoked == nil {
+		m.revoked = make(map[string]time.Time)
+	}
+
+	m.revoked[jti] = expiresAt
+	return nil
+}
+
+func (m *MockStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	if err := m.errs["IsTokenRevoked"]; err != nil {
+		return false, err
+	}
+
+	_, ok := m.revoked[jti]
+	return ok, nil
+}
+
+func (m *MockStore) RevokeUserTokens(ctx context.Context, userID int64, at time.Time) error {
+	if err := m.errs["RevokeUserTokens"]; err != nil {
+		return err
+	}
+
+	for _, u := range m.users {
+		if u.ID == userID {
+			u.TokensValidAfter = &at
+		}
+	}
+
+	for _, rt := range m.refreshTokens {
+		if rt.UserID == userID && rt.RevokedAt == nil {
+			rt.RevokedAt = &at
+		}
+	}
+
+	return nil
+}
+
+func (m *MockStore) DeleteExpiredRevokedTokens(ctx context.Context, before time.Time) (int64, error) {
+	if err := m.errs["DeleteExpiredRevokedTokens"]; err != nil {
+		return 0, err
+	}
+
+	var n int64
+	for jti, expiresAt := range m.revoked {
+		if expiresAt.Before(before) {
+			delete(m.revoked, jti)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (m *MockStore) CreateProject(ctx context.Context, orgID int64, p *Project) (*Project, error) {
+	if err := m.errs["CreateProject"]; err != nil {
+		return nil, err
+	}
+
+	if p.ID == 0 {
+		p.ID = int64(len(m.projects) + 1)
+	}
+
+	p.OrgID = orgID
+	m.projects = append(m.projects, p)
+	return p, nil
+}
+
+func (m *MockStore) GetProjectByID(ctx context.Context, orgID int64, id string) (*Project, error) {
+	if err := m.errs["GetProjectByID"]; err != nil {
+		return nil, err
+	}
+
+	for _, p := range m.projects {
+		if strconv.FormatInt(p.ID, 10) == id && p.OrgID == orgID {
+			return p, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) ListProjects(ctx context.Context, orgID int64, q ProjectQuery) (*ProjectPage, error) {
+	if err := m.errs["ListProjects"]; err != nil {
+		return nil, err
+	}
+
+	projects := []*Project{}
+	for _, p := range m.projects {
+		if p.OrgID != orgID {
+			continue
+		}
+
+		if _, err := m.GetProjectMember(ctx, orgID, p.ID, q.MemberID); q.MemberID != 0 && err != nil {
+			continue
+		}
+
+		projects = append(projects, p)
+	}
+
+	return pageProjects(projects, q), nil
+}
+
+func (m *MockStore) LockProject(ctx context.Context, orgID, projectID int64) error {
+	if err := m.errs["LockProject"]; err != nil {
+		return err
+	}
+
+	if !m.projectInOrg(orgID, projectID) {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *MockStore) DeleteProject(ctx context.Context, orgID int64, id string) (int64, error) {
+	if err := m.errs["DeleteProject"]; err != nil {
+		return 0, err
+	}
+
+	for i, p := range m.projects {
+		if strconv.FormatInt(p.ID, 10) == id && p.OrgID == orgID {
+			m.projects = append(m.projects[:i], m.projects[i+1:]...)
+			m.tasks = slices.DeleteFunc(m.tasks, func(t *Task) bool { return t.ProjectID == p.ID })
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (m *MockStore) AddProjectMember(ctx context.Context, orgID int64, pm *ProjectMember) (*ProjectMember, error) {
+	if err := m.errs["AddProjectMember"]; err != nil {
+		return nil, err
+	}
+
+	if !m.projectInOrg(orgID, pm.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	m.members = append(m.members, pm)
+	return pm, nil
+}
+
+func (m *MockStore) GetProjectMember(ctx context.Context, orgID, projectID, userID int64) (*ProjectMember, error) {
+	if err := m.errs["GetProjectMember"]; err != nil {
+		return nil, err
+	}
+
+	for _, pm := range m.members {
+		if pm.ProjectID == projectID && pm.UserID == userID && m.projectInOrg(orgID, projectID) {
+			return pm, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) ListProjectMembers(ctx context.Context, orgID, projectID int64) ([]*ProjectMember, error) {
+	if err := m.errs["ListProjectMembers"]; err != nil {
+		return nil, err
+	}
+
+	members := []*ProjectMember{}
+	if !m.projectInOrg(orgID, projectID) {
+		return members, nil
+	}
+
+	for _, pm := range m.members {
+		if pm.ProjectID == projectID {
+			members = append(members, pm)
+		}
+	}
+
+	return members, nil
+}
+
+func (m *MockStore) RemoveProjectMember(ctx context.Context, orgID, projectID, userID int64) (int64, error) {
+	if err := m.errs["RemoveProjectMember"]; err != nil {
+		return 0, err
+	}
+
+	if !m.projectInOrg(orgID, projectID) {
+		return 0, nil
+	}
+
+	for i, pm := range m.members {
+		if pm.ProjectID == projectID && pm.UserID == userID {
+			m.members = append(m.members[:i], m.members[i+1:]...)
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (m *MockStore) CreateProjectStatus(ctx context.Context, orgID int64, st *ProjectStatus) (*ProjectStatus, error) {
+	if err := m.errs["CreateProjectStatus"]; err != nil {
+		return nil, err
+	}
+
+	if !m.projectInOrg(orgID, st.ProjectID) {
+		return nil, ErrNotFound
+	}
+
+	st.ID = int64(len(m.statuses) + 100)
+	m.statuses = append(m.statuses, st)
+	return st, nil
+}
+
+func (m *MockStore) GetProjectStatus(ctx context.Context, orgID, projectID, id int64) (*ProjectStatus, error) {
+	if err := m.errs["GetProjectStatus"]; err != nil {
+		return nil, err
+	}
+
+	for _, st := range m.statuses {
+		if st.ID == id && st.ProjectID == projectID && m.projectInOrg(orgID, projectID) {
+			return st, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) ListProjectStatuses(ctx context.Context, orgID, projectID int64) ([]*ProjectStatus, error) {
+	if err := m.errs["ListProjectStatuses"]; err != nil {
+		return nil, err
+	}
+
+	statuses := []*ProjectStatus{}
+	if !m.projectInOrg(orgID, projectID) {
+		return statuses, nil
+	}
+
+	for _, st := range m.statuses {
+		if st.ProjectID == projectID {
+			statuses = append(statuses, st)
+		}
+	}
+
+	return statuses, nil
+}
+
+func (m *MockStore) UpdateProjectStatus(ctx context.Context, orgID, projectID, id int64, u ProjectStatusUpdate) (*ProjectStatus, error) {
+	if err := m.errs["UpdateProjectStatus"]; err != nil {
+		return nil, err
+	}
+
+	st, err := m.GetProjectStatus(ctx, orgID, projectID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Name != nil {
+		st.Name = *u.Name
+	}
+
+	if u.Category != nil {
+		st.Category = *u.Category
+	}
+
+	if u.Position != nil {
+		st.Position = *u.Position
+	}
+
+	return st, nil
+}
+
+func (m *MockStore) DeleteProjectStatus(ctx context.Context, orgID, projectID, id int64) (int64, error) {
+	if err := m.errs["DeleteProjectStatus"]; err != nil {
+		return 0, err
+	}
+
+	if !m.projectInOrg(orgID, projectID) {
+		return 0, nil
+	}
+
+	for i, st := range m.statuses {
+		if st.ID == id && st.ProjectID == projectID {
+			// a new slice, WithTx restores the old one
+			m.statuses = slices.Concat(m.statuses[:i], m.statuses[i+1:])
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (m *MockStore) ListWorkflowTransitions(ctx context.Context, orgID, projectID int64) ([]WorkflowTransition, error) {
+	if err := m.errs["ListWorkflowTransitions"]; err != nil {
+		return nil, err
+	}
+
+	if !m.projectInOrg(orgID, projectID) {
+		return []WorkflowTransition{}, nil
+	}
+
+	return append([]WorkflowTransition{}, m.workflows[projectID]...), nil
+}
+
+func (m *MockStore) ReplaceWorkflowTransitions(ctx context.Context, orgID, projectID int64, transitions []WorkflowTransition) error {
+	if err := m.errs["ReplaceWorkflowTransitions"]; err != nil {
+		return err
+	}
+
+	if !m.projectInOrg(orgID, projectID) {
+		return ErrNotFound
+	}
+
+	if m.workflows == nil {
+		m.workflows = make(map[int64][]WorkflowTransition)
+	}
+
+	m.workflows[projectID] = transitions
+	return nil
+}
+
+func (m *MockStore) CreateOrganization(ctx context.Context, o *Organization) (*Organization, error) {
+	if err := m.errs["CreateOrganization"]; err != nil {
+		return nil, err
+	}
+
+	o.ID = int64(len(m.orgs) + 1)
+	m.orgs = append(m.orgs, o)
+	return o, nil
+}
+
+func (m *MockStore) ListUserOrganizations(ctx context.Context, userID int64) ([]*Organization, error) {
+	if err := m.errs["ListUserOrganizations"]; err != nil {
+		return nil, err
+	}
+
+	orgs := []*Organization{}
+	for _, o := range m.orgs {
+		if _, err := m.GetOrganizationMember(ctx, o.ID, userID); err == nil {
+			orgs = append(orgs, o)
+		}
+	}
+
+	return orgs, nil
+}
+
+func (m *MockStore) AddOrganizationMember(ctx context.Context, om *OrganizationMember) (*OrganizationMember, error) {
+	if err := m.errs["AddOrganizationMember"]; err != nil {
+		return nil, err
+	}
+
+	m.orgMembers = append(m.orgMembers, om)
+	return om, nil
+}
+
+func (m *MockStore) GetOrganizationMember(ctx context.Context, orgID, userID int64) (*OrganizationMember, error) {
+	if err := m.errs["GetOrganizationMember"]; err != nil {
+		return nil, err
+	}
+
+	for _, om := range m.orgMembers {
+		if om.OrgID == orgID && om.UserID == userID {
+			return om, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m *MockStore) ListOrganizationMembers(ctx context.Context, orgID int64) ([]*OrganizationMember, error) {
+	if err := m.errs["ListOrganizationMembers"]; err != nil {
+		return nil, err
+	}
+
+	members := []*OrganizationMember{}
+	for _, om := range m.orgMembers {
+		if om.OrgID == orgID {
+			members = append(members, om)
+		}
+	}
+
+	return members, nil
+}
+
+// WithTx undoes what fn appended when it fails, which is all the handlers
+// under test need.
+func (m *MockStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	saved := *m
+	if err := fn(m); err != nil {
+		*m = saved
+		return err
+	}
+
+	return nil
+}
+
+// statusesOf are defaultStatuses in project projectID, with the ids
+// projectID*10+1 to projectID*10+4 for fixtures to refer to.
+func statusesOf(projectID int64) []*ProjectStatus {
+	statuses := []*ProjectStatus{}
+	for i, st := range defaultStatuses {
+		st.ID, st.ProjectID = projectID*10+int64(i)+1, projectID
+		statuses = append(statuses, &st)
+	}
+
+	return statuses
+}
+
+// projectInOrg is the org filter the SQL store applies by joining projects.
+func (m *MockStore) projectInOrg(orgID, projectID int64) bool {
+	for _, p := range m.projects {
+		if p.ID == projectID && p.OrgID == orgID {
+			return true
+		}
+	}
+
+	return false
+}
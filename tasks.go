@@ -1,88 +1,434 @@
-package main
-
-import (
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
-)
-
-var errTaskNameRequired = errors.New("name is required")
-var errProjectIDRequired = errors.New("project id is required")
-var errUserIDRequired = errors.New("user id is required")
-
-type TasksService struct {
-	store Store
-}
-
-func NewTasksService(s Store) *TasksService {
-	return &TasksService{
-		store: s,
-	}
-}
-
-func (s *TasksService) RegisterRoutes(r *http.ServeMux) {
-	r.HandleFunc("POST /tasks", WithJWTAuth(s.HandleCreateTask, s.store))
-	r.HandleFunc("GET /tasks/{task_id}", WithJWTAuth(s.HandleGetTask, s.store))
-}
-
-func (s *TasksService) HandleCreateTask(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error reading request body"})
-		return
-	}
-
-	defer r.Body.Close()
-
-	var task *Task
-	if err := json.Unmarshal(body, &task); err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error unmarshalling task payload"})
-		return
-	}
-
-	if err := validateTaskPayload(task); err != nil {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "error validating task payload" + err.Error()})
-		return
-	}
-
-	t, err := s.store.CreateTask(task)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error creating task: " + err.Error()})
-		return
-	}
-
-	WriteJSON(w, http.StatusCreated, t)
-}
-
-func (s *TasksService) HandleGetTask(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("task_id")
-	if id == "" {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "task id is required"})
-		return
-	}
-
-	t, err := s.store.GetTask(id)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error getting task: " + err.Error()})
-		return
-	}
-
-	WriteJSON(w, http.StatusOK, t)
-}
-
-func validateTaskPayload(task *Task) error {
-	if task.Name == "" {
-		return errTaskNameRequired
-	}
-
-	if task.ProjectID == 0 {
-		return errProjectIDRequired
-	}
-
-	if task.AssignedTo == 0 {
-		return errUserIDRequired
-	}
-
-	return nil
-}
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errAssigneeNotMember = errors.New("assignee is not a member of the project")
+
+type TasksService struct {
+	store Store
+}
+
+func NewTasksService(s Store) *TasksService {
+	return &TasksService{
+		store: s,
+	}
+}
+
+func (s *TasksService) RegisterRoutes(r *http.ServeMux) {
+	r.HandleFunc("POST /tasks", WithJWTAuth(RequirePermission(PermTasksCreate, s.HandleCreateTask), s.store))
+	r.HandleFunc("GET /tasks", WithJWTAuth(RequirePermission(PermTasksRead, s.HandleListTasks), s.store))
+	r.HandleFunc("GET /tasks/{task_id}", WithJWTAuth(RequirePermission(PermTasksRead, s.HandleGetTask), s.store))
+	r.HandleFunc("PATCH /tasks/{task_id}", WithJWTAuth(RequirePermission(PermTasksUpdate, s.HandleUpdateTask), s.store))
+	r.HandleFunc("GET /projects/{project_id}/tasks", WithJWTAuth(RequirePermission(PermTasksRead, s.HandleListProjectTasks), s.store))
+}
+
+func (s *TasksService) HandleCreateTask(w http.ResponseWriter, r *http.Request) {
+	var payload CreateTaskPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateTaskPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	u, ok := authorizeProject(w, r, s.store, orgID, payload.ProjectID, ProjectTasksWrite)
+	if !ok {
+		return
+	}
+
+	// tasks can only be handed to people working on the project
+	if _, err := s.store.GetProjectMember(r.Context(), orgID, payload.ProjectID, payload.AssignedTo); errors.Is(err, ErrNotFound) {
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidReference, errAssigneeNotMember.Error())
+		return
+	} else if err != nil {
+		WriteStoreError(w, r, err, "member")
+		return
+	}
+
+	// a task is only ever missing its project
+	t, err := s.store.CreateTask(r.Context(), orgID, &Task{
+		Name:       payload.Name,
+		ProjectID:  payload.ProjectID,
+		AssignedTo: payload.AssignedTo,
+		CreatedBy:  &u.ID,
+	})
+	if errors.Is(err, ErrNotFound) {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "task")
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, newTaskResponse(t))
+}
+
+func (s *TasksService) HandleGetTask(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("task_id")
+	if id == "" {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "task id is required")
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := s.store.GetTask(r.Context(), orgID, id)
+	if err != nil {
+		WriteStoreError(w, r, err, "task")
+		return
+	}
+
+	if _, ok := s.authorizeTask(w, r, orgID, t, ProjectRead); !ok {
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, newTaskResponse(t))
+}
+
+// authorizeTask is authorizeProject for the project of task t, except that
+// it answers users outside the project as if t didn't exist so they can't
+// tell which task ids do.
+func (s *TasksService) authorizeTask(w http.ResponseWriter, r *http.Request, orgID int64, t *Task, p ProjectPermission) (*User, bool) {
+	if u, ok := UserFromContext(r.Context()); ok && u.Role != RoleAdmin {
+		if _, err := s.store.GetProjectMember(r.Context(), orgID, t.ProjectID, u.ID); errors.Is(err, ErrNotFound) {
+			WriteStoreError(w, r, ErrNotFound, "task")
+			return nil, false
+		}
+	}
+
+	return authorizeProject(w, r, s.store, orgID, t.ProjectID, p)
+}
+
+// HandleUpdateTask applies a JSON Merge Patch to the task. Moving it to
+// another project takes writing tasks in both, and whoever it ends up
+// assigned to has to be a member of the project it ends up in. The status,
+// set by status_id or by name, is one of the project the task ends up in; a
+// moved task keeps the name of its status unless the patch sets one. Within
+// a project a new status has to be one the workflow leads to from the
+// current one.
+func (s *TasksService) HandleUpdateTask(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateTaskPayload
+	if !DecodeMergePatch(w, r, &payload) {
+		return
+	}
+
+	if err := validateUpdateTaskPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("task_id")
+	t, err := s.store.GetTask(r.Context(), orgID, id)
+	if err != nil {
+		WriteStoreError(w, r, err, "task")
+		return
+	}
+
+	u, ok := s.authorizeTask(w, r, orgID, t, ProjectTasksWrite)
+	if !ok {
+		return
+	}
+
+	update := TaskUpdate{
+		Name:       payload.Name.Ptr(),
+		AssignedTo: payload.AssignedTo.Ptr(),
+		ProjectID:  payload.ProjectID.Ptr(),
+	}
+
+	projectID, assignee := t.ProjectID, t.AssignedTo
+	if update.ProjectID != nil && *update.ProjectID != projectID {
+		projectID = *update.ProjectID
+		if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectTasksWrite); !ok {
+			return
+		}
+	}
+
+	if payload.Status.Set || payload.StatusID.Set || projectID != t.ProjectID {
+		statuses, err := s.store.ListProjectStatuses(r.Context(), orgID, projectID)
+		if err != nil {
+			WriteStoreError(w, r, err, "status")
+			return
+		}
+
+		i := slices.IndexFunc(statuses, func(st *ProjectStatus) bool {
+			if payload.StatusID.Set {
+				return st.ID == payload.StatusID.Value
+			}
+			if payload.Status.Set {
+				return st.Name == payload.Status.Value
+			}
+			return st.Name == t.Status
+		})
+		if i < 0 {
+			WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidReference, "the project has no such status")
+			return
+		}
+
+		if status := statuses[i]; status.ID != t.StatusID {
+			if projectID == t.ProjectID {
+				if !s.checkTransition(w, r, orgID, u, t, statuses, status) {
+					return
+				}
+
+				// a concurrent update may have moved it since
+				update.FromStatusID = &t.StatusID
+			}
+
+			update.StatusID = &status.ID
+		}
+	}
+
+	if update.AssignedTo != nil {
+		assignee = *update.AssignedTo
+	}
+
+	if projectID != t.ProjectID || assignee != t.AssignedTo {
+		if _, err := s.store.GetProjectMember(r.Context(), orgID, projectID, assignee); errors.Is(err, ErrNotFound) {
+			WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidReference, errAssigneeNotMember.Error())
+			return
+		} else if err != nil {
+			WriteStoreError(w, r, err, "member")
+			return
+		}
+	}
+
+	t, err = s.store.UpdateTask(r.Context(), orgID, id, update)
+	if err != nil {
+		WriteStoreError(w, r, err, "task")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, newTaskResponse(t))
+}
+
+// checkTransition checks the workflow of the project of t, which has
+// statuses, lets u move it to status and writes the error response when it
+// doesn't.
+func (s *TasksService) checkTransition(w http.ResponseWriter, r *http.Request, orgID int64, u *User, t *Task, statuses []*ProjectStatus, status *ProjectStatus) bool {
+	transitions, err := s.store.ListWorkflowTransitions(r.Context(), orgID, t.ProjectID)
+	if err != nil {
+		WriteStoreError(w, r, err, "workflow")
+		return false
+	}
+
+	tr, ok := newWorkflow(transitions, statuses).Transition(t.StatusID, status.ID)
+	if !ok {
+		WriteProblem(w, r, http.StatusConflict, CodeInvalidTransition, "tasks of this project can't move from "+t.Status+" to "+status.Name)
+		return false
+	}
+
+	allowed, err := tr.Guard.allows(r.Context(), s.store, orgID, u, t)
+	if err != nil {
+		WriteStoreError(w, r, err, "project member")
+		return false
+	}
+
+	if !allowed {
+		who := "the assignee"
+		if tr.Guard == GuardMaintainer {
+			who = "owners and maintainers"
+		}
+
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "only "+who+" may move the task to "+status.Name)
+		return false
+	}
+
+	return true
+}
+
+// HandleListTasks lists the tasks of the organization: all of them for
+// admins, those of the projects they are a member of for everyone else.
+func (s *TasksService) HandleListTasks(w http.ResponseWriter, r *http.Request) {
+	u, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	q, params, err := parseTaskQuery(r, u)
+	if err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	if u.Role != RoleAdmin {
+		q.MemberID = u.ID
+	}
+
+	s.writeTaskPage(w, r, orgID, q, params)
+}
+
+func (s *TasksService) HandleListProjectTasks(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	u, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectRead)
+	if !ok {
+		return
+	}
+
+	q, params, err := parseTaskQuery(r, u)
+	if err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	q.ProjectID = projectID
+	s.writeTaskPage(w, r, orgID, q, params)
+}
+
+func (s *TasksService) writeTaskPage(w http.ResponseWriter, r *http.Request, orgID int64, q TaskQuery, params PageParams) {
+	page, err := s.store.ListTasks(r.Context(), orgID, q)
+	if err != nil {
+		WriteStoreError(w, r, err, "task")
+		return
+	}
+
+	res := PageResponse[TaskResponse]{Items: make([]TaskResponse, 0, len(page.Tasks))}
+	for _, t := range page.Tasks {
+		res.Items = append(res.Items, newTaskResponse(t))
+	}
+
+	if page.More {
+		last := page.Tasks[len(page.Tasks)-1]
+		res.NextCursor = params.nextCursor(last.ID, last.Name, last.CreatedAt)
+	}
+
+	if q.CountTotal {
+		res.Total = &page.Total
+	}
+
+	WriteJSON(w, http.StatusOK, res)
+}
+
+// parseTaskQuery reads the query parameters of the task lists: those of
+// parsePageParams, status and category (repeated or comma separated),
+// assigned_to, which takes "me" for u, and the RFC 3339 times created_after
+// and created_before.
+func parseTaskQuery(r *http.Request, u *User) (TaskQuery, PageParams, error) {
+	var v Validator
+	query := r.URL.Query()
+	params := parsePageParams(&v, query, string(TaskSortCreatedAt), string(TaskSortName))
+
+	q := TaskQuery{
+		Sort:       TaskSort(params.Sort),
+		Desc:       params.Desc,
+		Limit:      params.Limit,
+		CountTotal: params.IncludeTotal,
+	}
+
+	for _, statuses := range query["status"] {
+		for _, status := range strings.Split(statuses, ",") {
+			v.String("status", status).Required().MaxLen(maxStatusNameLength)
+			q.Statuses = append(q.Statuses, status)
+		}
+	}
+
+	for _, categories := range query["category"] {
+		for _, category := range strings.Split(categories, ",") {
+			v.String("category", category).Required().OneOf(statusCategories...)
+			q.Categories = append(q.Categories, StatusCategory(category))
+		}
+	}
+
+	if assignee := query.Get("assigned_to"); assignee == "me" {
+		q.AssignedTo = u.ID
+	} else if assignee != "" {
+		id, err := strconv.ParseInt(assignee, 10, 64)
+		v.Check("assigned_to", err == nil && id > 0, "assigned to must be a user id or me")
+		q.AssignedTo = id
+	}
+
+	for _, bound := range []struct {
+		field string
+		t     *time.Time
+	}{
+		{"created_after", &q.CreatedAfter},
+		{"created_before", &q.CreatedBefore},
+	} {
+		if value := query.Get(bound.field); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			v.Check(bound.field, err == nil, fieldLabel(bound.field)+" must be an RFC 3339 time like 2026-01-02T15:04:05Z")
+			*bound.t = t
+		}
+	}
+
+	if c := params.After; c != nil {
+		q.After = &Task{ID: c.ID, Name: c.Name, CreatedAt: c.CreatedAt}
+	}
+
+	return q, params, v.Err()
+}
+
+func validateTaskPayload(payload *CreateTaskPayload) error {
+	var v Validator
+	v.String("name", payload.Name).Required().MaxLen(maxNameLength)
+	v.ID("project_id", payload.ProjectID).Required()
+	v.ID("assigned_to", payload.AssignedTo).Required()
+	return v.Err()
+}
+
+// validateUpdateTaskPayload checks the fields the patch sets like those of a
+// new task. None of them can be removed by setting it to null, and the
+// status is set either by name or by id.
+func validateUpdateTaskPayload(payload *UpdateTaskPayload) error {
+	var v Validator
+	if payload.Name.Set && v.NotNull("name", payload.Name.Null) {
+		v.String("name", payload.Name.Value).Required().MaxLen(maxNameLength)
+	}
+
+	if payload.Status.Set && v.NotNull("status", payload.Status.Null) {
+		v.String("status", payload.Status.Value).Required().MaxLen(maxStatusNameLength)
+	}
+
+	if payload.StatusID.Set && v.NotNull("status_id", payload.StatusID.Null) {
+		v.ID("status_id", payload.StatusID.Value).Required()
+		v.Check("status_id", !payload.Status.Set, "status id can't be set together with status")
+	}
+
+	if payload.AssignedTo.Set && v.NotNull("assigned_to", payload.AssignedTo.Null) {
+		v.ID("assigned_to", payload.AssignedTo.Value).Required()
+	}
+
+	if payload.ProjectID.Set && v.NotNull("project_id", payload.ProjectID.Null) {
+		v.ID("project_id", payload.ProjectID.Value).Required()
+	}
+
+	return v.Err()
+}
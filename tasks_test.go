@@ -1,82 +1,496 @@
-package main
-
-import (
-	"bytes"
-	"encoding/json"
-	"net/http"
-	"net/http/httptest"
-	"testing"
-)
-
-func TestCreateTask(t *testing.T) {
-
-	ms := &MockStore{}
-	service := NewTasksService(ms)
-
-	t.Run("should return error if task name is missing", func(t *testing.T) {
-		payload := &CreateTaskPayload{
-			Name: "",
-		}
-
-		b, err := json.Marshal(payload)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		req, err := http.NewRequest(http.MethodPost, "/tasks", bytes.NewBuffer(b))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("/tasks", service.HandleCreateTask)
-
-		router.ServeHTTP(rr, req)
-
-		// it should be failing case cuz, name is empty...
-		if rr.Code != http.StatusBadRequest {
-			t.Error("invalid status code, it should pass")
-		}
-
-		// var response ErrorResponse
-		// err = json.NewDecoder(rr.Body).Decode(&response)
-		// if err != nil {
-		// 	t.Fatal(err)
-		// }
-
-		// if response.Error != errTaskNameRequired.Error() {
-		// 	t.Errorf("expected error message %s, got %s", errTaskNameRequired, response.Error)
-		// }
-	})
-
-	t.Run("should create a task", func(t *testing.T) {
-		payload := &CreateTaskPayload{
-			Name:       "REST API IN GO",
-			ProjectID:  3,
-			AssignedTo: 26,
-		}
-
-		b, err := json.Marshal(payload)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		req, err := http.NewRequest(http.MethodPost, "/tasks", bytes.NewBuffer(b))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("/tasks", service.HandleCreateTask)
-
-		router.ServeHTTP(rr, req)
-
-		if rr.Code != http.StatusCreated {
-			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
-		}
-	})
-}
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// taskRoutes serves the task handlers on ms without the auth middleware.
+func taskRoutes(ms *MockStore) http.Handler {
+	router := http.NewServeMux()
+
+	service := NewTasksService(ms)
+	router.HandleFunc("POST /tasks", service.HandleCreateTask)
+	router.HandleFunc("GET /tasks", service.HandleListTasks)
+	router.HandleFunc("GET /tasks/{task_id}", service.HandleGetTask)
+	router.HandleFunc("PATCH /tasks/{task_id}", service.HandleUpdateTask)
+	router.HandleFunc("GET /projects/{project_id}/tasks", service.HandleListProjectTasks)
+
+	return router
+}
+
+func TestCreateTask(t *testing.T) {
+
+	ms := &MockStore{
+		projects: []*Project{{ID: 3, OrgID: testOrgID}},
+		members: []*ProjectMember{
+			{ProjectID: 3, UserID: 26, Role: ProjectRoleContributor},
+		},
+	}
+
+	t.Run("should return error if task name is missing", func(t *testing.T) {
+		rr := serveAs(t, taskRoutes(ms), 26, http.MethodPost, "/tasks", &CreateTaskPayload{Name: "", ProjectID: 3, AssignedTo: 26})
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should create a task", func(t *testing.T) {
+		payload := &CreateTaskPayload{
+			Name:       "REST API IN GO",
+			ProjectID:  3,
+			AssignedTo: 26,
+		}
+
+		rr := serveAs(t, taskRoutes(ms), 26, http.MethodPost, "/tasks", payload)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if task := ms.tasks[len(ms.tasks)-1]; task.CreatedBy == nil || *task.CreatedBy != 26 {
+			t.Errorf("expected task to be created by user 26, got %v", task.CreatedBy)
+		}
+	})
+}
+
+func TestTaskProjectMembership(t *testing.T) {
+	newStore := func() *MockStore {
+		return &MockStore{
+			projects: []*Project{{ID: 1, OrgID: testOrgID}, {ID: 2, OrgID: testOrgID}},
+			members: []*ProjectMember{
+				{ProjectID: 1, UserID: 1, Role: ProjectRoleContributor},
+				{ProjectID: 1, UserID: 2, Role: ProjectRoleViewer},
+				{ProjectID: 2, UserID: 3, Role: ProjectRoleOwner},
+			},
+			tasks: []*Task{
+				{ID: 10, Name: "write docs", ProjectID: 1, AssignedTo: 1},
+			},
+		}
+	}
+
+	create := func(t *testing.T, caller *User, payload *CreateTaskPayload) int {
+		return serveAsUser(t, taskRoutes(newStore()), caller, http.MethodPost, "/tasks", payload).Code
+	}
+
+	get := func(t *testing.T, caller *User, path string) int {
+		return serveAsUser(t, taskRoutes(newStore()), caller, http.MethodGet, path, nil).Code
+	}
+
+	t.Run("should forbid creating tasks in someone else's project", func(t *testing.T) {
+		code := create(t, &User{ID: 3, Role: RoleMember}, &CreateTaskPayload{Name: "task", ProjectID: 1, AssignedTo: 1})
+		if code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, code)
+		}
+	})
+
+	t.Run("should forbid project viewers from creating tasks", func(t *testing.T) {
+		code := create(t, &User{ID: 2, Role: RoleMember}, &CreateTaskPayload{Name: "task", ProjectID: 1, AssignedTo: 1})
+		if code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, code)
+		}
+	})
+
+	t.Run("should reject assignees outside the project", func(t *testing.T) {
+		code := create(t, &User{ID: 1, Role: RoleMember}, &CreateTaskPayload{Name: "task", ProjectID: 1, AssignedTo: 3})
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, code)
+		}
+	})
+
+	t.Run("should let project viewers read tasks", func(t *testing.T) {
+		if code := get(t, &User{ID: 2, Role: RoleMember}, "/tasks/10"); code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, code)
+		}
+	})
+
+	t.Run("should hide tasks of someone else's project", func(t *testing.T) {
+		// the same answer as for a task that doesn't exist
+		for _, path := range []string{"/tasks/10", "/tasks/11"} {
+			if code := get(t, &User{ID: 3, Role: RoleMember}, path); code != http.StatusNotFound {
+				t.Errorf("expected status code %d for %s, got %d", http.StatusNotFound, path, code)
+			}
+		}
+	})
+
+	t.Run("should let admins read any task", func(t *testing.T) {
+		if code := get(t, &User{ID: 99, Role: RoleAdmin}, "/tasks/10"); code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d", http.StatusOK, code)
+		}
+	})
+}
+
+func TestListTasks(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ms := &MockStore{
+		projects: []*Project{{ID: 1, OrgID: testOrgID}, {ID: 2, OrgID: testOrgID}},
+		members: []*ProjectMember{
+			{ProjectID: 1, UserID: 1, Role: ProjectRoleContributor},
+		},
+		tasks: []*Task{
+			{ID: 1, Name: "design", Status: "DONE", StatusCategory: StatusCategoryDone, ProjectID: 1, AssignedTo: 1, CreatedAt: created},
+			{ID: 2, Name: "build", Status: "IN_PROGRESS", StatusCategory: StatusCategoryInProgress, ProjectID: 1, AssignedTo: 1, CreatedAt: created.Add(24 * time.Hour)},
+			{ID: 3, Name: "test", Status: "TODO", StatusCategory: StatusCategoryTodo, ProjectID: 1, AssignedTo: 2, CreatedAt: created.Add(48 * time.Hour)},
+			{ID: 4, Name: "ship", Status: "TODO", StatusCategory: StatusCategoryTodo, ProjectID: 1, AssignedTo: 1, CreatedAt: created.Add(72 * time.Hour)},
+			{ID: 5, Name: "hidden", Status: "TODO", StatusCategory: StatusCategoryTodo, ProjectID: 2, AssignedTo: 1, CreatedAt: created},
+		},
+	}
+	list := func(t *testing.T, u *User, path string) (*httptest.ResponseRecorder, PageResponse[TaskResponse]) {
+		rr := serveAsUser(t, taskRoutes(ms), u, http.MethodGet, path, nil)
+
+		var page PageResponse[TaskResponse]
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return rr, page
+	}
+
+	ids := func(page PageResponse[TaskResponse]) []int64 {
+		ids := []int64{}
+		for _, t := range page.Items {
+			ids = append(ids, t.ID)
+		}
+		return ids
+	}
+
+	member := &User{ID: 1, Role: RoleMember}
+
+	t.Run("should list my open tasks of the projects I am a member of", func(t *testing.T) {
+		rr, page := list(t, member, "/tasks?assigned_to=me&status=TODO,IN_PROGRESS&sort=-created_at")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if got := ids(page); !reflect.DeepEqual(got, []int64{4, 2}) {
+			t.Errorf("expected tasks [4 2], got %v", got)
+		}
+	})
+
+	t.Run("should filter by the category of the status", func(t *testing.T) {
+		rr, page := list(t, member, "/tasks?category=in_progress&category=done")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if got := ids(page); !reflect.DeepEqual(got, []int64{1, 2}) {
+			t.Errorf("expected tasks [1 2], got %v", got)
+		}
+	})
+
+	t.Run("should page through the tasks of a project", func(t *testing.T) {
+		path := "/projects/1/tasks?limit=2&sort=name&include_total=true&created_after=2026-01-02T00:00:00Z"
+		rr, page := list(t, member, path)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if got := ids(page); !reflect.DeepEqual(got, []int64{2, 4}) || page.Total == nil || *page.Total != 3 {
+			t.Errorf("expected tasks [2 4] of 3, got %v of %v", got, page.Total)
+		}
+
+		_, page = list(t, member, path+"&cursor="+page.NextCursor)
+		if got := ids(page); !reflect.DeepEqual(got, []int64{3}) || page.NextCursor != "" {
+			t.Errorf("expected the last page [3], got %v with cursor %q", got, page.NextCursor)
+		}
+	})
+
+	t.Run("should forbid projects of others", func(t *testing.T) {
+		if rr, _ := list(t, member, "/projects/2/tasks"); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should reject invalid filters", func(t *testing.T) {
+		rr, _ := list(t, member, "/tasks?status=&category=open&assigned_to=bob&created_before=yesterday")
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		fields := []string{}
+		for _, f := range p.Errors {
+			fields = append(fields, f.Field)
+		}
+
+		if want := []string{"status", "category", "assigned_to", "created_before"}; !reflect.DeepEqual(fields, want) {
+			t.Errorf("expected errors for %v, got %+v", want, p.Errors)
+		}
+	})
+}
+
+func TestUpdateTask(t *testing.T) {
+	newStore := func() *MockStore {
+		return &MockStore{
+			projects: []*Project{{ID: 1, OrgID: testOrgID}, {ID: 2, OrgID: testOrgID}, {ID: 3, OrgID: testOrgID}},
+			members: []*ProjectMember{
+				{ProjectID: 1, UserID: 1, Role: ProjectRoleContributor},
+				{ProjectID: 1, UserID: 2, Role: ProjectRoleViewer},
+				{ProjectID: 2, UserID: 1, Role: ProjectRoleContributor},
+				{ProjectID: 3, UserID: 2, Role: ProjectRoleOwner},
+				{ProjectID: 2, UserID: 4, Role: ProjectRoleContributor},
+				{ProjectID: 2, UserID: 5, Role: ProjectRoleMaintainer},
+			},
+			tasks: []*Task{
+				{ID: 10, Name: "write docs", StatusID: 11, Status: "TODO", ProjectID: 1, AssignedTo: 1},
+				{ID: 11, Name: "review docs", StatusID: 23, Status: "IN_TESTING", ProjectID: 2, AssignedTo: 4},
+			},
+			statuses: slices.Concat(statusesOf(1), statusesOf(2), statusesOf(3)),
+			workflows: map[int64][]WorkflowTransition{
+				2: {
+					{FromStatusID: 23, ToStatusID: 24, Guard: GuardAssignee},
+					{FromStatusID: 23, ToStatusID: 22, Guard: GuardMaintainer},
+				},
+			},
+		}
+	}
+
+	patch := func(t *testing.T, ms *MockStore, caller *User, path, body string) *httptest.ResponseRecorder {
+		return serveAsUser(t, taskRoutes(ms), caller, http.MethodPatch, path, body)
+	}
+
+	contributor := &User{ID: 1, Role: RoleMember}
+
+	t.Run("should only change the fields of the patch", func(t *testing.T) {
+		ms := newStore()
+
+		rr := patch(t, ms, contributor, "/tasks/10", `{"status": "IN_PROGRESS"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		var task TaskResponse
+		if err := json.NewDecoder(rr.Body).Decode(&task); err != nil {
+			t.Fatal(err)
+		}
+
+		if task.Status != "IN_PROGRESS" || task.Name != "write docs" || task.AssignedTo != 1 || task.ProjectID != 1 {
+			t.Errorf("expected only the status to change, got %+v", task)
+		}
+
+		if ms.tasks[0].StatusID != 12 || ms.tasks[0].Status != "IN_PROGRESS" {
+			t.Errorf("expected the task to be stored, got %+v", ms.tasks[0])
+		}
+	})
+
+	t.Run("should set the status by id", func(t *testing.T) {
+		ms := newStore()
+
+		if rr := patch(t, ms, contributor, "/tasks/10", `{"status_id": 12}`); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if task := ms.tasks[0]; task.StatusID != 12 || task.Status != "IN_PROGRESS" {
+			t.Errorf("expected the task in status 12, got %+v", task)
+		}
+	})
+
+	t.Run("should move the task to another project", func(t *testing.T) {
+		ms := newStore()
+
+		if rr := patch(t, ms, contributor, "/tasks/10", `{"project_id": 2, "name": "write more docs"}`); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		// into the status of the same name
+		if task := ms.tasks[0]; task.ProjectID != 2 || task.Name != "write more docs" || task.StatusID != 21 {
+			t.Errorf("expected the task to move, got %+v", task)
+		}
+	})
+
+	t.Run("should move the task into any status of the other project", func(t *testing.T) {
+		ms := newStore()
+
+		// the workflow only applies within a project
+		if rr := patch(t, ms, contributor, "/tasks/10", `{"project_id": 2, "status": "DONE"}`); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if task := ms.tasks[0]; task.ProjectID != 2 || task.StatusID != 24 {
+			t.Errorf("expected the task to move, got %+v", task)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		caller *User
+		path   string
+		body   string
+		want   int
+		fields []string
+	}{
+		{
+			name:   "should validate every field of the patch",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"name": null, "status": "", "assigned_to": 0, "project_id": null}`,
+			want:   http.StatusBadRequest,
+			fields: []string{"name", "status", "assigned_to", "project_id"},
+		},
+		{
+			name:   "should reject a status by name and by id",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"status": "IN_PROGRESS", "status_id": 12}`,
+			want:   http.StatusBadRequest,
+			fields: []string{"status_id"},
+		},
+		{
+			name:   "should reject statuses the project lacks",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"status": "BLOCKED"}`,
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "should reject statuses of other projects",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"status_id": 22}`,
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "should reject fields that can't be patched",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"created_by": 2}`,
+			want:   http.StatusBadRequest,
+			fields: []string{"created_by"},
+		},
+		{
+			name:   "should forbid project viewers",
+			caller: &User{ID: 2, Role: RoleMember},
+			path:   "/tasks/10",
+			body:   `{"status": "DONE"}`,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "should hide tasks of projects of others",
+			caller: &User{ID: 4, Role: RoleMember},
+			path:   "/tasks/10",
+			body:   `{"status": "IN_PROGRESS"}`,
+			want:   http.StatusNotFound,
+		},
+		{
+			name:   "should forbid moving tasks to projects of others",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"project_id": 3}`,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "should reject assignees outside the project",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"assigned_to": 3}`,
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "should keep the assignee in the project the task moves to",
+			caller: &User{ID: 9, Role: RoleAdmin},
+			path:   "/tasks/10",
+			body:   `{"project_id": 3}`,
+			want:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:   "should return 404 for an unknown task",
+			caller: contributor,
+			path:   "/tasks/99",
+			body:   `{"status": "DONE"}`,
+			want:   http.StatusNotFound,
+		},
+		{
+			name:   "should reject transitions the default workflow lacks",
+			caller: contributor,
+			path:   "/tasks/10",
+			body:   `{"status": "DONE"}`,
+			want:   http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newStore()
+
+			rr := patch(t, ms, tt.caller, tt.path, tt.body)
+			if rr.Code != tt.want {
+				t.Fatalf("expected status code %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+
+			if tt.fields != nil {
+				var p Problem
+				if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+					t.Fatal(err)
+				}
+
+				fields := []string{}
+				for _, f := range p.Errors {
+					fields = append(fields, f.Field)
+				}
+
+				if !reflect.DeepEqual(fields, tt.fields) {
+					t.Errorf("expected errors for %v, got %+v", tt.fields, p.Errors)
+				}
+			}
+
+			if task := ms.tasks[0]; task.StatusID != 11 || task.ProjectID != 1 || task.AssignedTo != 1 {
+				t.Errorf("expected the task to stay as it was, got %+v", task)
+			}
+		})
+	}
+
+	workflowTests := []struct {
+		name   string
+		caller *User
+		status string
+		want   int
+	}{
+		{"should let the assignee through an assignee guard", &User{ID: 4, Role: RoleMember}, "DONE", http.StatusOK},
+		{"should stop others at an assignee guard", contributor, "DONE", http.StatusForbidden},
+		{"should let maintainers through a maintainer guard", &User{ID: 5, Role: RoleMember}, "IN_PROGRESS", http.StatusOK},
+		{"should stop contributors at a maintainer guard", &User{ID: 4, Role: RoleMember}, "IN_PROGRESS", http.StatusForbidden},
+		{"should let admins through every guard", &User{ID: 9, Role: RoleAdmin}, "IN_PROGRESS", http.StatusOK},
+		{"should reject transitions the project's workflow lacks", &User{ID: 4, Role: RoleMember}, "TODO", http.StatusConflict},
+	}
+
+	for _, tt := range workflowTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := newStore()
+
+			rr := patch(t, ms, tt.caller, "/tasks/11", `{"status": "`+tt.status+`"}`)
+			if rr.Code != tt.want {
+				t.Fatalf("expected status code %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+
+			want := "IN_TESTING"
+			if tt.want == http.StatusOK {
+				want = tt.status
+			}
+
+			if task := ms.tasks[1]; task.Status != want {
+				t.Errorf("expected status %s, got %+v", want, task)
+			}
+		})
+	}
+
+	t.Run("should fail when the status changed meanwhile", func(t *testing.T) {
+		ms := newStore()
+		ms.errs = map[string]error{"UpdateTask": errTaskStatusChanged}
+
+		if rr := patch(t, ms, contributor, "/tasks/10", `{"status": "IN_PROGRESS"}`); rr.Code != http.StatusConflict {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body)
+		}
+	})
+}
@@ -1,48 +1,361 @@
-package main
-
-import "time"
-
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
-type Task struct {
-	ID         int64     `json:"id"`
-	Name       string    `json:"name"`
-	Status     string    `json:"status"`
-	ProjectID  int64     `json:"project_id"`
-	AssignedTo int64     `json:"assigned_to"`
-	CreatedAt  time.Time `json:"created_at"`
-}
-
-type CreateTaskPayload struct {
-	Name       string `json:"name"`
-	ProjectID  int64  `json:"project_id"`
-	AssignedTo int64  `json:"assigned_to"`
-}
-
-type User struct {
-	ID        int64  `json:"id"`
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
-	CreatedAt string `json:"created_at"`
-}
-
-type RegisterUserPayload struct {
-	FirstName string `json:"first_name"`
-	LastName  string `json:"last_name"`
-	Email     string `json:"email"`
-	Password  string `json:"password"`
-}
-
-type Project struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-}
-
-type CreateProjectPayload struct {
-	Name string `json:"name"`
-}
+package main
+
+import "time"
+
+// Task, User and Project are rows of the store. Responses use TaskResponse,
+// UserResponse and ProjectResponse instead, so a column only reaches clients
+// once it is mapped there.
+type Task struct {
+	ID       int64
+	Name     string
+	StatusID int64
+	// Status and StatusCategory are read from the status, writes only
+	// change StatusID
+	Status         string
+	StatusCategory StatusCategory
+	ProjectID      int64
+	AssignedTo     int64
+	CreatedBy      *int64
+	CreatedAt      time.Time
+}
+
+type TaskResponse struct {
+	ID             int64          `json:"id"`
+	Name           string         `json:"name"`
+	StatusID       int64          `json:"status_id"`
+	Status         string         `json:"status"`
+	StatusCategory StatusCategory `json:"status_category"`
+	ProjectID      int64          `json:"project_id"`
+	AssignedTo     int64          `json:"assigned_to"`
+	CreatedBy      *int64         `json:"created_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+func newTaskResponse(t *Task) TaskResponse {
+	return TaskResponse{
+		ID:             t.ID,
+		Name:           t.Name,
+		StatusID:       t.StatusID,
+		Status:         t.Status,
+		StatusCategory: t.StatusCategory,
+		ProjectID:      t.ProjectID,
+		AssignedTo:     t.AssignedTo,
+		CreatedBy:      t.CreatedBy,
+		CreatedAt:      t.CreatedAt,
+	}
+}
+
+// UpdateTaskPayload is a JSON Merge Patch of a task, fields left out stay as
+// they are. The status is set either by id or by name.
+type UpdateTaskPayload struct {
+	Name       PatchField[string] `json:"name"`
+	StatusID   PatchField[int64]  `json:"status_id"`
+	Status     PatchField[string] `json:"status"`
+	AssignedTo PatchField[int64]  `json:"assigned_to"`
+	ProjectID  PatchField[int64]  `json:"project_id"`
+}
+
+// TaskUpdate lists the columns Store.UpdateTask changes, nil ones stay as
+// they are.
+type TaskUpdate struct {
+	Name       *string
+	StatusID   *int64
+	AssignedTo *int64
+	ProjectID  *int64
+	// FromStatusID, when StatusID changes it, only updates the task while it
+	// is still in this status. Otherwise UpdateTask fails with ErrConflict.
+	FromStatusID *int64
+}
+
+// TaskQuery selects a page of the tasks of an organization for
+// Store.ListTasks. Every filter left zero matches all tasks.
+type TaskQuery struct {
+	ProjectID int64
+	// MemberID only lists tasks of projects this user is a member of
+	MemberID   int64
+	AssignedTo int64
+	// Statuses matches tasks in a status of any of these names, Categories
+	// in a status of any of these categories
+	Statuses   []string
+	Categories []StatusCategory
+	// CreatedAfter and CreatedBefore bound created_at, the first one
+	// inclusively
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	Sort TaskSort
+	Desc bool
+	// After is the last task of the previous page, nil for the first page.
+	// Only its id and the column sorted by are read.
+	After *Task
+	// Limit is the size of the page, it has to be positive
+	Limit int
+	// CountTotal asks for TaskPage.Total
+	CountTotal bool
+}
+
+type TaskSort string
+
+const (
+	TaskSortCreatedAt TaskSort = "created_at"
+	TaskSortName      TaskSort = "name"
+)
+
+type TaskPage struct {
+	Tasks []*Task
+	// More is set when tasks follow the page
+	More bool
+	// Total counts the matching tasks of every page, if asked for
+	Total int64
+}
+
+type CreateTaskPayload struct {
+	Name       string `json:"name"`
+	ProjectID  int64  `json:"project_id"`
+	AssignedTo int64  `json:"assigned_to"`
+}
+
+type User struct {
+	ID        int64
+	FirstName string
+	LastName  string
+	Email     string
+	// the bcrypt hash, kept out of JSON should a User ever be encoded
+	Password  string `json:"-"`
+	Role      Role
+	CreatedAt string
+
+	// tokens issued at or before this instant were revoked by a logout-all
+	TokensValidAfter *time.Time
+}
+
+type UserResponse struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Role      Role   `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+func newUserResponse(u *User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Role:      u.Role,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+type RegisterUserPayload struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+}
+
+type UpdateUserRolePayload struct {
+	Role Role `json:"role"`
+}
+
+type LoginUserPayload struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type Project struct {
+	ID        int64
+	OrgID     int64
+	Name      string
+	CreatedBy *int64
+	CreatedAt time.Time
+}
+
+type ProjectResponse struct {
+	ID        int64     `json:"id"`
+	OrgID     int64     `json:"org_id"`
+	Name      string    `json:"name"`
+	CreatedBy *int64    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newProjectResponse(p *Project) ProjectResponse {
+	return ProjectResponse{
+		ID:        p.ID,
+		OrgID:     p.OrgID,
+		Name:      p.Name,
+		CreatedBy: p.CreatedBy,
+		CreatedAt: p.CreatedAt,
+	}
+}
+
+// ProjectQuery selects a page of the projects of an organization for
+// Store.ListProjects.
+type ProjectQuery struct {
+	// MemberID only lists the projects this user is a member of, 0 lists all
+	MemberID int64
+	// NamePrefix and Search match the name case-insensitively, from its
+	// start and anywhere in it
+	NamePrefix string
+	Search     string
+
+	Sort ProjectSort
+	Desc bool
+	// After is the last project of the previous page, nil for the first
+	// page. Only its id and the column sorted by are read.
+	After *Project
+	// Limit is the size of the page, it has to be positive
+	Limit int
+	// CountTotal asks for ProjectPage.Total
+	CountTotal bool
+}
+
+type ProjectSort string
+
+const (
+	ProjectSortCreatedAt ProjectSort = "created_at"
+	ProjectSortName      ProjectSort = "name"
+)
+
+type ProjectPage struct {
+	Projects []*Project
+	// More is set when projects follow the page
+	More bool
+	// Total counts the matching projects of every page, if asked for
+	Total int64
+}
+
+type CreateProjectPayload struct {
+	Name string `json:"name"`
+}
+
+type ProjectMember struct {
+	ProjectID int64       `json:"project_id"`
+	UserID    int64       `json:"user_id"`
+	Role      ProjectRole `json:"role"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type AddProjectMemberPayload struct {
+	UserID int64       `json:"user_id"`
+	Role   ProjectRole `json:"role"`
+}
+
+// ProjectStatus is one of the statuses the tasks of a project can be in.
+// Statuses are ordered by position, then id.
+type ProjectStatus struct {
+	ID        int64
+	ProjectID int64
+	Name      string
+	Category  StatusCategory
+	Position  int
+	CreatedAt time.Time
+}
+
+type ProjectStatusResponse struct {
+	ID        int64          `json:"id"`
+	ProjectID int64          `json:"project_id"`
+	Name      string         `json:"name"`
+	Category  StatusCategory `json:"category"`
+	Position  int            `json:"position"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+func newProjectStatusResponse(st *ProjectStatus) ProjectStatusResponse {
+	return ProjectStatusResponse{
+		ID:        st.ID,
+		ProjectID: st.ProjectID,
+		Name:      st.Name,
+		Category:  st.Category,
+		Position:  st.Position,
+		CreatedAt: st.CreatedAt,
+	}
+}
+
+type CreateProjectStatusPayload struct {
+	Name     string         `json:"name"`
+	Category StatusCategory `json:"category"`
+	// Position defaults to right after the last status
+	Position *int `json:"position"`
+}
+
+// UpdateProjectStatusPayload is a JSON Merge Patch of a status.
+type UpdateProjectStatusPayload struct {
+	Name     PatchField[string]         `json:"name"`
+	Category PatchField[StatusCategory] `json:"category"`
+	Position PatchField[int]            `json:"position"`
+}
+
+// ProjectStatusUpdate lists the columns Store.UpdateProjectStatus changes,
+// nil ones stay as they are.
+type ProjectStatusUpdate struct {
+	Name     *string
+	Category *StatusCategory
+	Position *int
+}
+
+// WorkflowTransition lets the tasks of a project move from one of its
+// statuses to another, see Workflow.
+type WorkflowTransition struct {
+	FromStatusID int64           `json:"from_status_id"`
+	ToStatusID   int64           `json:"to_status_id"`
+	Guard        TransitionGuard `json:"guard,omitempty"`
+}
+
+// WorkflowPayload replaces the workflow of a project, no transitions at all
+// restore the default one.
+type WorkflowPayload struct {
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+type WorkflowResponse struct {
+	Transitions []WorkflowTransition `json:"transitions"`
+	// Default is set while the project follows the default workflow
+	Default bool `json:"default"`
+}
+
+type Organization struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy *int64    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateOrganizationPayload struct {
+	Name string `json:"name"`
+}
+
+type OrganizationMember struct {
+	OrgID     int64     `json:"org_id"`
+	UserID    int64     `json:"user_id"`
+	Role      OrgRole   `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AddOrganizationMemberPayload struct {
+	UserID int64   `json:"user_id"`
+	Role   OrgRole `json:"role"`
+}
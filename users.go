@@ -1,124 +1,212 @@
-package main
-
-import (
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
-)
-
-var errEmailRequired = errors.New("email is required")
-var errFirstNameRequired = errors.New("first name is required")
-var errLastNameRequired = errors.New("last name is required")
-var errPasswordRequired = errors.New("password is required")
-
-type UserService struct {
-	store Store
-}
-
-func NewUserService(s Store) *UserService {
-	return &UserService{
-		store: s,
-	}
-}
-
-func (s *UserService) RegisterRoutes(r *http.ServeMux) {
-	r.HandleFunc("POST /users/register", s.HandleUserRegister)
-	r.HandleFunc("GET /users/{user_id}", WithJWTAuth(s.HandleUserGet, s.store))
-}
-
-func (s *UserService) HandleUserGet(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("user_id")
-	if id == "" {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "missing field user id"})
-		return
-	}
-
-	u, err := s.store.GetUserByID(id)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error getting user by id"})
-		return
-	}
-
-	WriteJSON(w, http.StatusOK, u)
-}
-
-func (s *UserService) HandleUserRegister(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "error reading request body", http.StatusBadRequest)
-		return
-	}
-
-	defer r.Body.Close()
-
-	var payload *User
-	err = json.Unmarshal(body, &payload)
-	if err != nil {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: "Invalid request payload"})
-		return
-	}
-
-	if err := validateUserPayload(payload); err != nil {
-		WriteJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
-		return
-	}
-
-	hashedPWD, err := HashPassword(payload.Password)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error hashing password"})
-		return
-	}
-
-	payload.Password = hashedPWD
-	u, err := s.store.CreateUser(payload)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error creating user"})
-		return
-	}
-
-	token, err := createAndSetAuthCookie(u.ID, w)
-	if err != nil {
-		WriteJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "error while setting cookie"})
-		return
-	}
-
-	response := map[string]string{"token": token}
-	WriteJSON(w, http.StatusCreated, response)
-}
-
-func validateUserPayload(user *User) error {
-	if user.Email == "" {
-		return errEmailRequired
-	}
-
-	// both first_name and last_name, you can make them optional....
-	if user.FirstName == "" {
-		return errFirstNameRequired
-	}
-
-	if user.LastName == "" {
-		return errLastNameRequired
-	}
-
-	if user.Password == "" {
-		return errPasswordRequired
-	}
-
-	return nil
-}
-
-func createAndSetAuthCookie(id int64, w http.ResponseWriter) (string, error) {
-	secret := []byte(Envs.JWTSecret)
-	token, err := CreateJWT(secret, id)
-	if err != nil {
-		return "", err
-	}
-
-	http.SetCookie(w, &http.Cookie{
-		Name:  "Authorization",
-		Value: token,
-	})
-
-	return token, nil
-}
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+var errInvalidCredentials = errors.New("invalid email or password")
+
+// dummyPasswordHash is what passwords given for unknown emails are compared
+// with, so that login takes as long as it does for a wrong password.
+const dummyPasswordHash = "$2a$10$qH96j1NScAUFSMgIZpJoJ..NVBl.TrjRpvW5IQw6IhSHrRZQAIRuW"
+
+type UserService struct {
+	store Store
+}
+
+func NewUserService(s Store) *UserService {
+	return &UserService{
+		store: s,
+	}
+}
+
+func (s *UserService) RegisterRoutes(r *http.ServeMux) {
+	r.HandleFunc("POST /users/register", s.HandleUserRegister)
+	r.HandleFunc("POST /users/login", s.HandleUserLogin)
+	r.HandleFunc("GET /users/{user_id}", WithJWTAuth(RequirePermission(PermUsersRead, s.HandleUserGet), s.store))
+	r.HandleFunc("PUT /users/{user_id}/role", WithJWTAuth(RequirePermission(PermUsersManage, s.HandleUserRoleUpdate), s.store))
+}
+
+func (s *UserService) HandleUserGet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("user_id")
+	if id == "" {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "missing field user id")
+		return
+	}
+
+	// everyone may read their own profile, only some roles anyone else's
+	caller, ok := UserFromContext(r.Context())
+	if !ok {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "permission denied")
+		return
+	}
+
+	if strconv.FormatInt(caller.ID, 10) != id && !caller.Role.Can(PermUsersReadAll) {
+		WriteProblem(w, r, http.StatusForbidden, CodeForbidden, "forbidden")
+		return
+	}
+
+	u, err := s.store.GetUserByID(r.Context(), id)
+	if err != nil {
+		WriteStoreError(w, r, err, "user")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, newUserResponse(u))
+}
+
+func (s *UserService) HandleUserRegister(w http.ResponseWriter, r *http.Request) {
+	var payload RegisterUserPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateUserPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	hashedPWD, err := HashPassword(payload.Password)
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error hashing password")
+		return
+	}
+
+	var u *User
+	err = s.store.WithTx(r.Context(), func(tx Store) error {
+		var err error
+		u, err = tx.CreateUser(r.Context(), &User{
+			FirstName: payload.FirstName,
+			LastName:  payload.LastName,
+			Email:     payload.Email,
+			Password:  hashedPWD,
+			// roles are only ever granted by an admin
+			Role: RoleMember,
+		})
+		if err != nil {
+			return err
+		}
+
+		// everyone starts out with a personal organization of their own,
+		// named after them as far as the name column allows
+		name := []rune(u.FirstName + " " + u.LastName)
+		if len(name) > maxNameLength {
+			name = name[:maxNameLength]
+		}
+
+		_, err = createOrganization(r.Context(), tx, string(name), u.ID)
+		return err
+	})
+	if err != nil {
+		WriteStoreError(w, r, err, "user")
+		return
+	}
+
+	tokens, err := issueTokens(r.Context(), w, s.store, u.ID, "")
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error while setting cookie")
+		return
+	}
+
+	WriteJSON(w, http.StatusCreated, tokens)
+}
+
+func (s *UserService) HandleUserRoleUpdate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("user_id")
+	if id == "" {
+		WriteProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "missing field user id")
+		return
+	}
+
+	var payload UpdateUserRolePayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateUserRolePayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	n, err := s.store.UpdateUserRole(r.Context(), id, payload.Role)
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "user")
+		return
+	}
+
+	u, err := s.store.GetUserByID(r.Context(), id)
+	if err != nil {
+		WriteStoreError(w, r, err, "user")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, newUserResponse(u))
+}
+
+func (s *UserService) HandleUserLogin(w http.ResponseWriter, r *http.Request) {
+	var payload LoginUserPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateLoginPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	// same response, after as long, for unknown email and wrong password, so
+	// callers can't probe for accounts
+	u, err := s.store.GetUserByEmail(r.Context(), payload.Email)
+	if errors.Is(err, ErrNotFound) {
+		ComparePasswords(dummyPasswordHash, payload.Password)
+		WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidCredentials, errInvalidCredentials.Error())
+		return
+	}
+
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error getting user by email")
+		return
+	}
+
+	if !ComparePasswords(u.Password, payload.Password) {
+		WriteProblem(w, r, http.StatusUnauthorized, CodeInvalidCredentials, errInvalidCredentials.Error())
+		return
+	}
+
+	tokens, err := issueTokens(r.Context(), w, s.store, u.ID, "")
+	if err != nil {
+		WriteProblem(w, r, http.StatusInternalServerError, CodeInternal, "error while setting cookie")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, tokens)
+}
+
+func validateUserPayload(user *RegisterUserPayload) error {
+	var v Validator
+	v.String("email", user.Email).Required().MaxLen(maxNameLength).Email()
+	// both first_name and last_name, you can make them optional....
+	v.String("first_name", user.FirstName).Required().MaxLen(maxNameLength)
+	v.String("last_name", user.LastName).Required().MaxLen(maxNameLength)
+	v.String("password", user.Password).Required().MaxBytes(maxPasswordBytes)
+	return v.Err()
+}
+
+func validateLoginPayload(payload *LoginUserPayload) error {
+	var v Validator
+	v.String("email", payload.Email).Required()
+	v.String("password", payload.Password).Required()
+	return v.Err()
+}
+
+func validateUserRolePayload(payload *UpdateUserRolePayload) error {
+	var v Validator
+	v.Check("role", payload.Role.Valid(), "role must be one of admin, member or viewer")
+	return v.Err()
+}
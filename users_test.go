@@ -1,160 +1,450 @@
-package main
-
-import (
-	"bytes"
-	"encoding/json"
-	"net/http"
-	"net/http/httptest"
-	"testing"
-)
-
-func TestCreateUser(t *testing.T) {
-	ms := &MockStore{}
-	service := NewUserService(ms)
-
-	t.Run("should validate if email is empty", func(t *testing.T) {
-		payload := &RegisterUserPayload{
-			FirstName: "bob",
-			LastName:  "cj",
-			Email:     "",
-			Password:  "5Vi64w^&",
-		}
-
-		b, err := json.Marshal(payload)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		req, err := http.NewRequest(http.MethodPost, "/users/register", bytes.NewBuffer(b))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("POST /users/register", service.HandleUserRegister)
-
-		router.ServeHTTP(rr, req)
-
-		if rr.Code != http.StatusBadRequest {
-			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
-		}
-
-		var response ErrorResponse
-		err = json.NewDecoder(rr.Body).Decode(&response)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		if response.Error != errEmailRequired.Error() {
-			t.Errorf("expected error message %s, got %s", response.Error, errEmailRequired.Error())
-		}
-	})
-
-	t.Run("should create a user", func(t *testing.T) {
-		payload := &RegisterUserPayload{
-			FirstName: "bob",
-			LastName:  "cj",
-			Email:     "bob@gmail.com",
-			Password:  "5Vi64w^&",
-		}
-
-		b, err := json.Marshal(payload)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		req, err := http.NewRequest(http.MethodPost, "/users/register", bytes.NewBuffer(b))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		rr := httptest.NewRecorder()
-		router := http.NewServeMux()
-
-		router.HandleFunc("POST /users/register", service.HandleUserRegister)
-
-		router.ServeHTTP(rr, req)
-
-		if rr.Code != http.StatusCreated {
-			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
-		}
-	})
-
-}
-
-func TestValidateUserPayload(t *testing.T) {
-	type args struct {
-		user *User
-	}
-
-	tests := []struct {
-		name string
-		args args
-		want error
-	}{
-		{
-			name: "should return error if email is empty",
-			args: args{
-				user: &User{
-					FirstName: "manoj",
-					LastName:  "g",
-				},
-			},
-			want: errEmailRequired,
-		},
-		{
-			name: "should return error if first name is empty",
-			args: args{
-				user: &User{
-					LastName: "g",
-					Email:    "manoj@gmail.com",
-				},
-			},
-			want: errFirstNameRequired,
-		},
-		{
-			name: "should return error if last name is empty",
-			args: args{
-				user: &User{
-					FirstName: "manoj",
-					Email:     "manoj@gmail.com",
-				},
-			},
-			want: errLastNameRequired,
-		},
-		{
-			name: "should return error if password is empty",
-			args: args{
-				user: &User{
-					FirstName: "manoj",
-					LastName:  "g",
-					Email:     "manoj@gmail.com",
-				},
-			},
-			want: errPasswordRequired,
-		},
-		{
-			name: "should return nil if all the fields are present",
-			args: args{
-				user: &User{
-					FirstName: "manoj",
-					LastName:  "g",
-					Email:     "manoj@gmail.com",
-					Password:  "wf#$w7",
-				},
-			},
-			want: nil,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := validateUserPayload(tt.args.user); got != tt.want {
-				t.Errorf("validateUserPayload() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-
-}
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCreateUser(t *testing.T) {
+	ms := &MockStore{}
+	service := NewUserService(ms)
+
+	t.Run("should validate if email is empty", func(t *testing.T) {
+		payload := &RegisterUserPayload{
+			FirstName: "bob",
+			LastName:  "cj",
+			Email:     "",
+			Password:  "5Vi64w^&",
+		}
+
+		b, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/users/register", bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+
+		router.HandleFunc("POST /users/register", service.HandleUserRegister)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		var response Problem
+		err = json.NewDecoder(rr.Body).Decode(&response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Code != CodeValidationFailed || len(response.Errors) != 1 || response.Errors[0].Field != "email" {
+			t.Errorf("expected a %s problem for the email, got %+v", CodeValidationFailed, response)
+		}
+	})
+
+	t.Run("should create a user", func(t *testing.T) {
+		payload := &RegisterUserPayload{
+			FirstName: "bob",
+			LastName:  "cj",
+			Email:     "bob@gmail.com",
+			Password:  "5Vi64w^&",
+		}
+
+		b, err := json.Marshal(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/users/register", bytes.NewBuffer(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+
+		router.HandleFunc("POST /users/register", service.HandleUserRegister)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusCreated {
+			t.Errorf("expected status code %d, got %d", http.StatusCreated, rr.Code)
+		}
+
+		if u := ms.users[len(ms.users)-1]; u.Role != RoleMember {
+			t.Errorf("expected role %s, got %s", RoleMember, u.Role)
+		}
+	})
+
+	t.Run("should not let clients pick their own role", func(t *testing.T) {
+		body := `{"first_name":"eve","last_name":"cj","email":"eve@gmail.com","password":"5Vi64w^&","role":"admin"}`
+
+		req, err := http.NewRequest(http.MethodPost, "/users/register", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+
+		router.HandleFunc("POST /users/register", service.HandleUserRegister)
+
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		for _, u := range ms.users {
+			if u.Email == "eve@gmail.com" {
+				t.Errorf("expected no user to be created, got %+v", u)
+			}
+		}
+	})
+
+}
+
+func TestValidateUserPayload(t *testing.T) {
+	type args struct {
+		user *RegisterUserPayload
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{
+			name: "should return error if email is empty",
+			args: args{
+				user: &RegisterUserPayload{
+					FirstName: "manoj",
+					LastName:  "g",
+					Password:  "wf#$w7",
+				},
+			},
+			want: []string{"email"},
+		},
+		{
+			name: "should return error if first name is empty",
+			args: args{
+				user: &RegisterUserPayload{
+					LastName: "g",
+					Email:    "manoj@gmail.com",
+					Password: "wf#$w7",
+				},
+			},
+			want: []string{"first_name"},
+		},
+		{
+			name: "should return error if last name is empty",
+			args: args{
+				user: &RegisterUserPayload{
+					FirstName: "manoj",
+					Email:     "manoj@gmail.com",
+					Password:  "wf#$w7",
+				},
+			},
+			want: []string{"last_name"},
+		},
+		{
+			name: "should return error if password is empty",
+			args: args{
+				user: &RegisterUserPayload{
+					FirstName: "manoj",
+					LastName:  "g",
+					Email:     "manoj@gmail.com",
+				},
+			},
+			want: []string{"password"},
+		},
+		{
+			name: "should report every invalid field at once",
+			args: args{
+				user: &RegisterUserPayload{
+					FirstName: strings.Repeat("m", 256),
+					Email:     "manoj",
+				},
+			},
+			want: []string{"email", "first_name", "last_name", "password"},
+		},
+		{
+			name: "should return error if the password is longer than bcrypt hashes",
+			args: args{
+				user: &RegisterUserPayload{
+					FirstName: "manoj",
+					LastName:  "g",
+					Email:     "manoj@gmail.com",
+					Password:  strings.Repeat("p", 73),
+				},
+			},
+			want: []string{"password"},
+		},
+		{
+			name: "should return nil if all the fields are present",
+			args: args{
+				user: &RegisterUserPayload{
+					FirstName: "manoj",
+					LastName:  "g",
+					Email:     "manoj@gmail.com",
+					Password:  "wf#$w7",
+				},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUserPayload(tt.args.user)
+
+			var got []string
+			var validationErr *ValidationError
+			if errors.As(err, &validationErr) {
+				for _, f := range validationErr.Fields {
+					got = append(got, f.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("expected a *ValidationError, got %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateUserPayload() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+}
+
+func TestLoginUser(t *testing.T) {
+	hashed, err := HashPassword("5Vi64w^&")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ms := &MockStore{
+		users: []*User{
+			{ID: 1, FirstName: "bob", LastName: "cj", Email: "bob@gmail.com", Password: hashed},
+		},
+	}
+	service := NewUserService(ms)
+
+	tests := []struct {
+		name    string
+		payload *LoginUserPayload
+		want    int
+	}{
+		{
+			name:    "should return a token for valid credentials",
+			payload: &LoginUserPayload{Email: "bob@gmail.com", Password: "5Vi64w^&"},
+			want:    http.StatusOK,
+		},
+		{
+			name:    "should reject a wrong password",
+			payload: &LoginUserPayload{Email: "bob@gmail.com", Password: "wrong"},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "should reject an unknown email",
+			payload: &LoginUserPayload{Email: "alice@gmail.com", Password: "5Vi64w^&"},
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "should validate if email is empty",
+			payload: &LoginUserPayload{Password: "5Vi64w^&"},
+			want:    http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewBuffer(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+
+			rr := httptest.NewRecorder()
+			router := http.NewServeMux()
+
+			router.HandleFunc("POST /users/login", service.HandleUserLogin)
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, rr.Code)
+			}
+
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var response TokenResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			if response.Token == "" || response.RefreshToken == "" {
+				t.Error("expected an access and a refresh token in the response")
+			}
+		})
+	}
+}
+
+func TestDummyPasswordHash(t *testing.T) {
+	// a hash bcrypt can't parse fails right away, giving unknown emails away
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil || cost != bcrypt.DefaultCost {
+		t.Errorf("expected a hash of cost %d, got %d, %v", bcrypt.DefaultCost, cost, err)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	ms := &MockStore{
+		users: []*User{
+			{ID: 1, Email: "admin@gmail.com", Role: RoleAdmin},
+			{ID: 2, Email: "bob@gmail.com", Role: RoleMember},
+			{ID: 3, Email: "alice@gmail.com", Role: RoleMember},
+		},
+	}
+	service := NewUserService(ms)
+
+	tests := []struct {
+		name   string
+		caller *User
+		path   string
+		want   int
+	}{
+		{
+			name:   "should return the caller's own profile",
+			caller: ms.users[1],
+			path:   "/users/2",
+			want:   http.StatusOK,
+		},
+		{
+			name:   "should forbid a member reading another user",
+			caller: ms.users[1],
+			path:   "/users/3",
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "should let an admin read any user",
+			caller: ms.users[0],
+			path:   "/users/3",
+			want:   http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req = authenticate(req, tt.caller)
+
+			rr := httptest.NewRecorder()
+			router := http.NewServeMux()
+
+			router.HandleFunc("GET /users/{user_id}", service.HandleUserGet)
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Errorf("expected status code %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	newStore := func() *MockStore {
+		return &MockStore{
+			users: []*User{
+				{ID: 1, Email: "admin@gmail.com", Role: RoleAdmin},
+				{ID: 2, Email: "bob@gmail.com", Role: RoleMember},
+			},
+		}
+	}
+
+	update := func(t *testing.T, ms *MockStore, caller int64, path, role string) *httptest.ResponseRecorder {
+		token, err := CreateJWT(Keys.Current(), caller, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"role": "`+role+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+
+		rr := httptest.NewRecorder()
+		router := http.NewServeMux()
+
+		NewUserService(ms).RegisterRoutes(router)
+
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("should let an admin change a role", func(t *testing.T) {
+		ms := newStore()
+
+		if rr := update(t, ms, 1, "/users/2/role", "viewer"); rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		if ms.users[1].Role != RoleViewer {
+			t.Errorf("expected role %s, got %s", RoleViewer, ms.users[1].Role)
+		}
+	})
+
+	t.Run("should forbid a member from changing roles", func(t *testing.T) {
+		ms := newStore()
+
+		if rr := update(t, ms, 2, "/users/2/role", "admin"); rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+
+		if ms.users[1].Role != RoleMember {
+			t.Errorf("expected role %s, got %s", RoleMember, ms.users[1].Role)
+		}
+	})
+
+	t.Run("should validate the role", func(t *testing.T) {
+		if rr := update(t, newStore(), 1, "/users/2/role", "root"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+	})
+
+	t.Run("should return not found for an unknown user", func(t *testing.T) {
+		if rr := update(t, newStore(), 1, "/users/9/role", "viewer"); rr.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
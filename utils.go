@@ -1,12 +1,204 @@
-package main
-
-import (
-	"encoding/json"
-	"net/http"
-)
-
-func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(v)
-}
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// DecodeJSON decodes the body of r into the payload v, or answers r with the
+// problem and returns false.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decodeBody(w, r, v, "application/json")
+}
+
+// DecodeMergePatch decodes a JSON Merge Patch (RFC 7396) into the struct v
+// points to like DecodeJSON, also taking its own media type. v's fields are
+// usually PatchFields.
+func DecodeMergePatch(w http.ResponseWriter, r *http.Request, v any) bool {
+	if !decodeBody(w, r, v, "application/merge-patch+json", "application/json") {
+		return false
+	}
+
+	if p := decodePatchValues(v); p != nil {
+		writeProblem(w, r, p)
+		return false
+	}
+
+	return true
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any, mediaTypes ...string) bool {
+	if p := decodeJSON(w, r, v, mediaTypes...); p != nil {
+		writeProblem(w, r, p)
+		return false
+	}
+
+	return true
+}
+
+// decodeJSON decodes the body of r into v. The body has to be a single JSON
+// value of one of mediaTypes, of at most Envs.MaxBodyBytes, and may only set
+// the fields v has.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any, mediaTypes ...string) *Problem {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !slices.Contains(mediaTypes, mediaType) {
+		return &Problem{Status: http.StatusUnsupportedMediaType, Code: CodeUnsupportedMedia, Detail: "request body must be " + joinOr(mediaTypes)}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, Envs.MaxBodyBytes)
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		// anything but whitespace after the value
+		if _, err = dec.Token(); err == io.EOF {
+			return nil
+		}
+
+		var maxBytesErr *http.MaxBytesError
+		if !errors.As(err, &maxBytesErr) {
+			return invalidBody("request body must contain a single JSON value")
+		}
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &Problem{Status: http.StatusRequestEntityTooLarge, Code: CodeBodyTooLarge, Detail: "request body must be at most " + formatBytes(maxBytesErr.Limit)}
+	case errors.Is(err, io.EOF):
+		return invalidBody("request body is empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return invalidBody("request body is not valid JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fieldProblem(typeErr.Field, FieldInvalid, fieldLabel(typeErr.Field)+" must be "+jsonTypeName(typeErr.Type))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for this one
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return fieldProblem(field, FieldUnknown, fieldLabel(field)+" can't be set")
+	}
+
+	return invalidBody("request body must be a JSON object")
+}
+
+// PatchField is a field of a JSON Merge Patch. Set tells whether the patch
+// has the field at all, Null whether it sets it to null.
+type PatchField[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+
+	raw json.RawMessage
+}
+
+// UnmarshalJSON only keeps the value, decodePatchValues decodes it. An error
+// returned from here wouldn't tell which field it is about.
+func (f *PatchField[T]) UnmarshalJSON(b []byte) error {
+	f.Set, f.Null = true, string(b) == "null"
+	f.raw = append(f.raw[:0], b...)
+	return nil
+}
+
+func (f *PatchField[T]) decodeValue() error {
+	if !f.Set || f.Null {
+		return nil
+	}
+
+	return json.Unmarshal(f.raw, &f.Value)
+}
+
+// decodePatchValues decodes the values of the PatchFields of the struct v
+// points to.
+func decodePatchValues(v any) *Problem {
+	rv := reflect.ValueOf(v).Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		if !rv.Type().Field(i).IsExported() {
+			continue
+		}
+
+		f, ok := rv.Field(i).Addr().Interface().(interface{ decodeValue() error })
+		if !ok {
+			continue
+		}
+
+		var typeErr *json.UnmarshalTypeError
+		if err := f.decodeValue(); errors.As(err, &typeErr) {
+			field, _, _ := strings.Cut(rv.Type().Field(i).Tag.Get("json"), ",")
+			return fieldProblem(field, FieldInvalid, fieldLabel(field)+" must be "+jsonTypeName(typeErr.Type))
+		} else if err != nil {
+			return invalidBody("request body is not valid JSON")
+		}
+	}
+
+	return nil
+}
+
+// Ptr returns a pointer to the value of a field the patch sets, otherwise
+// nil.
+func (f PatchField[T]) Ptr() *T {
+	if !f.Set || f.Null {
+		return nil
+	}
+
+	return &f.Value
+}
+
+func invalidBody(detail string) *Problem {
+	return &Problem{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Detail: detail}
+}
+
+func fieldProblem(field, code, detail string) *Problem {
+	return &Problem{
+		Status: http.StatusBadRequest,
+		Code:   CodeValidationFailed,
+		Detail: detail,
+		Errors: []FieldError{{Field: field, Code: code, Detail: detail}},
+	}
+}
+
+// jsonTypeName is how a JSON document spells values of t.
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+
+	return "an object"
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return strconv.FormatInt(n>>20, 10) + " MiB"
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return strconv.FormatInt(n>>10, 10) + " KiB"
+	}
+
+	return strconv.FormatInt(n, 10) + " bytes"
+}
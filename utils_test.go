@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMergePatch(t *testing.T) {
+	decode := func(t *testing.T, contentType, body string) (*httptest.ResponseRecorder, UpdateTaskPayload) {
+		req, err := http.NewRequest(http.MethodPatch, "/tasks/1", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+
+		rr := httptest.NewRecorder()
+
+		var payload UpdateTaskPayload
+		if DecodeMergePatch(rr, req, &payload) {
+			rr.WriteHeader(http.StatusOK)
+		}
+
+		return rr, payload
+	}
+
+	t.Run("should tell left out, null and set fields apart", func(t *testing.T) {
+		rr, payload := decode(t, "application/merge-patch+json", `{"name": null, "assigned_to": 2}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if !payload.Name.Set || !payload.Name.Null || payload.Name.Ptr() != nil {
+			t.Errorf("expected name to be set to null, got %+v", payload.Name)
+		}
+
+		if p := payload.AssignedTo.Ptr(); !payload.AssignedTo.Set || payload.AssignedTo.Null || p == nil || *p != 2 {
+			t.Errorf("expected assigned to to be set to 2, got %+v", payload.AssignedTo)
+		}
+
+		if payload.Status.Set || payload.Status.Ptr() != nil {
+			t.Errorf("expected status to be left out, got %+v", payload.Status)
+		}
+	})
+
+	t.Run("should also take application/json", func(t *testing.T) {
+		if rr, _ := decode(t, "application/json", `{}`); rr.Code != http.StatusOK {
+			t.Errorf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+	})
+
+	t.Run("should name the field of the wrong type", func(t *testing.T) {
+		rr, _ := decode(t, "application/merge-patch+json", `{"assigned_to": "me"}`)
+
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		if rr.Code != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != "assigned_to" {
+			t.Errorf("expected an error for assigned_to, got %d %+v", rr.Code, p)
+		}
+	})
+}
+
+func TestDecodeJSON(t *testing.T) {
+	limit := Envs.MaxBodyBytes
+	Envs.MaxBodyBytes = 64
+	defer func() { Envs.MaxBodyBytes = limit }()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		code        string
+		field       string
+	}{
+		{
+			name:        "should decode a payload",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name": "website"} `,
+			want:        http.StatusOK,
+		},
+		{
+			name: "should require a content type",
+			body: `{"name": "website"}`,
+			want: http.StatusUnsupportedMediaType,
+			code: CodeUnsupportedMedia,
+		},
+		{
+			name:        "should reject forms",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `name=website`,
+			want:        http.StatusUnsupportedMediaType,
+			code:        CodeUnsupportedMedia,
+		},
+		{
+			name:        "should limit the body size",
+			contentType: "application/json",
+			body:        `{"name": "` + strings.Repeat("w", 64) + `"}`,
+			want:        http.StatusRequestEntityTooLarge,
+			code:        CodeBodyTooLarge,
+		},
+		{
+			name:        "should reject fields of the server",
+			contentType: "application/json",
+			body:        `{"name": "website", "created_by": 1}`,
+			want:        http.StatusBadRequest,
+			code:        CodeValidationFailed,
+			field:       "created_by",
+		},
+		{
+			name:        "should reject values of the wrong type",
+			contentType: "application/json",
+			body:        `{"name": 1}`,
+			want:        http.StatusBadRequest,
+			code:        CodeValidationFailed,
+			field:       "name",
+		},
+		{
+			name:        "should reject trailing data",
+			contentType: "application/json",
+			body:        `{"name": "website"}{"name": "blog"}`,
+			want:        http.StatusBadRequest,
+			code:        CodeInvalidRequest,
+		},
+		{
+			name:        "should reject malformed JSON",
+			contentType: "application/json",
+			body:        `{"name": "website"`,
+			want:        http.StatusBadRequest,
+			code:        CodeInvalidRequest,
+		},
+		{
+			name:        "should reject an empty body",
+			contentType: "application/json",
+			want:        http.StatusBadRequest,
+			code:        CodeInvalidRequest,
+		},
+		{
+			name:        "should reject other values than objects",
+			contentType: "application/json",
+			body:        `["website"]`,
+			want:        http.StatusBadRequest,
+			code:        CodeInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "/projects", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+
+			var payload CreateProjectPayload
+			if DecodeJSON(rr, req, &payload) {
+				rr.WriteHeader(http.StatusOK)
+			}
+
+			if rr.Code != tt.want {
+				t.Fatalf("expected status code %d, got %d: %s", tt.want, rr.Code, rr.Body)
+			}
+
+			if tt.want == http.StatusOK {
+				if payload.Name != "website" {
+					t.Errorf("expected the name website, got %+v", payload)
+				}
+				return
+			}
+
+			var p Problem
+			if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Code != tt.code {
+				t.Errorf("expected code %s, got %+v", tt.code, p)
+			}
+
+			if tt.field != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tt.field) {
+				t.Errorf("expected an error for the field %s, got %+v", tt.field, p.Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The codes of field errors.
+const (
+	FieldRequired = "required"
+	FieldTooLong  = "too_long"
+	FieldInvalid  = "invalid"
+	// the field isn't part of the request body
+	FieldUnknown = "unknown"
+)
+
+// maxNameLength is the size of the VARCHAR(255) name and email columns.
+const maxNameLength = 255
+
+// maxPasswordBytes is as much of a password as bcrypt hashes.
+const maxPasswordBytes = 72
+
+// ValidationError lists every invalid field of a request body.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	details := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		details[i] = f.Detail
+	}
+
+	return strings.Join(details, "; ")
+}
+
+// Validator collects the invalid fields of a request body, e.g.
+//
+//	var v Validator
+//	v.String("email", p.Email).Required().MaxLen(maxNameLength).Email()
+//	v.ID("project_id", p.ProjectID).Required()
+//	return v.Err()
+//
+// The rules of a field stop at its first failure, so every field is
+// reported at most once.
+type Validator struct {
+	fields []FieldError
+}
+
+// Err returns a *ValidationError listing the invalid fields, or nil.
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: v.fields}
+}
+
+// Check reports field as invalid with detail unless ok.
+func (v *Validator) Check(field string, ok bool, detail string) {
+	if !ok {
+		v.fail(field, FieldInvalid, detail)
+	}
+}
+
+// NotNull reports field as required when a patch sets it to null, and
+// returns whether it didn't.
+func (v *Validator) NotNull(field string, null bool) bool {
+	if null {
+		v.fail(field, FieldRequired, fieldLabel(field)+" can't be null")
+	}
+
+	return !null
+}
+
+func (v *Validator) fail(field, code, detail string) {
+	v.fields = append(v.fields, FieldError{Field: field, Code: code, Detail: detail})
+}
+
+// String starts the rules for a string field. Only Required rejects the
+// empty string, the other rules skip it so optional fields can be left out.
+func (v *Validator) String(field, value string) *StringRules {
+	return &StringRules{v: v, field: field, value: value}
+}
+
+type StringRules struct {
+	v      *Validator
+	field  string
+	value  string
+	failed bool
+}
+
+func (r *StringRules) check(ok bool, code, detail string) *StringRules {
+	if !r.failed && !ok {
+		r.v.fail(r.field, code, detail)
+		r.failed = true
+	}
+
+	return r
+}
+
+func (r *StringRules) Required() *StringRules {
+	return r.check(r.value != "", FieldRequired, fieldLabel(r.field)+" is required")
+}
+
+// MaxLen limits the value to n characters, as VARCHAR(n) does.
+func (r *StringRules) MaxLen(n int) *StringRules {
+	return r.check(utf8.RuneCountInString(r.value) <= n, FieldTooLong, fieldLabel(r.field)+" must be at most "+strconv.Itoa(n)+" characters")
+}
+
+// MaxBytes limits the value to n bytes.
+func (r *StringRules) MaxBytes(n int) *StringRules {
+	return r.check(len(r.value) <= n, FieldTooLong, fieldLabel(r.field)+" must be at most "+strconv.Itoa(n)+" bytes")
+}
+
+// Email requires a bare address like bob@gmail.com, without a display name.
+func (r *StringRules) Email() *StringRules {
+	ok := r.value == ""
+	if !ok {
+		addr, err := mail.ParseAddress(r.value)
+		ok = err == nil && addr.Address == r.value
+	}
+
+	return r.check(ok, FieldInvalid, fieldLabel(r.field)+" must be a valid email address")
+}
+
+func (r *StringRules) OneOf(values ...string) *StringRules {
+	ok := r.value == ""
+	for _, v := range values {
+		ok = ok || r.value == v
+	}
+
+	return r.check(ok, FieldInvalid, fieldLabel(r.field)+" must be one of "+joinOr(values))
+}
+
+// ID starts the rules for a field referencing a row by id.
+func (v *Validator) ID(field string, value int64) *IDRules {
+	return &IDRules{v: v, field: field, value: value}
+}
+
+type IDRules struct {
+	v     *Validator
+	field string
+	value int64
+}
+
+func (r *IDRules) Required() *IDRules {
+	if r.value == 0 {
+		r.v.fail(r.field, FieldRequired, fieldLabel(r.field)+" is required")
+	} else if r.value < 0 {
+		r.v.fail(r.field, FieldInvalid, fieldLabel(r.field)+" must be a positive id")
+	}
+
+	return r
+}
+
+// fieldLabel is how details name field: "first_name" is "first name".
+func fieldLabel(field string) string {
+	return strings.ReplaceAll(field, "_", " ")
+}
+
+// joinOr joins values like "a, b or c".
+func joinOr(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
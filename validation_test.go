@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules func(v *Validator)
+		want  []FieldError
+	}{
+		{
+			name: "should pass valid fields",
+			rules: func(v *Validator) {
+				v.String("name", "website").Required().MaxLen(7)
+				v.String("email", "bob@gmail.com").Email()
+				v.String("category", "done").OneOf(statusCategories...)
+				v.ID("project_id", 3).Required()
+				v.Check("role", true, "role is invalid")
+			},
+		},
+		{
+			name: "should skip the rules of empty optional fields",
+			rules: func(v *Validator) {
+				v.String("email", "").MaxLen(3).Email()
+				v.String("category", "").OneOf(statusCategories...)
+			},
+		},
+		{
+			name: "should count characters, not bytes",
+			rules: func(v *Validator) {
+				v.String("name", "ü").MaxLen(1)
+				v.String("password", "ü").MaxBytes(1)
+			},
+			want: []FieldError{
+				{Field: "password", Code: FieldTooLong, Detail: "password must be at most 1 bytes"},
+			},
+		},
+		{
+			name: "should report a field once",
+			rules: func(v *Validator) {
+				v.String("first_name", "").Required().MaxLen(255)
+				v.String("email", strings.Repeat("a", 300)).MaxLen(255).Email()
+			},
+			want: []FieldError{
+				{Field: "first_name", Code: FieldRequired, Detail: "first name is required"},
+				{Field: "email", Code: FieldTooLong, Detail: "email must be at most 255 characters"},
+			},
+		},
+		{
+			name: "should reject malformed values",
+			rules: func(v *Validator) {
+				v.String("email", "Bob <bob@gmail.com>").Email()
+				v.String("category", "blocked").OneOf(statusCategories...)
+				v.ID("assigned_to", -1).Required()
+				v.ID("project_id", 0).Required()
+			},
+			want: []FieldError{
+				{Field: "email", Code: FieldInvalid, Detail: "email must be a valid email address"},
+				{Field: "category", Code: FieldInvalid, Detail: "category must be one of todo, in_progress or done"},
+				{Field: "assigned_to", Code: FieldInvalid, Detail: "assigned to must be a positive id"},
+				{Field: "project_id", Code: FieldRequired, Detail: "project id is required"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v Validator
+			tt.rules(&v)
+
+			err := v.Err()
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a *ValidationError, got %v", err)
+			}
+
+			if !reflect.DeepEqual(validationErr.Fields, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, validationErr.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+)
+
+// TransitionGuard limits who may take a WorkflowTransition, the empty guard
+// lets through everyone who may write tasks of the project.
+type TransitionGuard string
+
+const (
+	// GuardAssignee only lets through the user the task is assigned to
+	GuardAssignee TransitionGuard = "assignee"
+	// GuardMaintainer only lets through owners and maintainers of the project
+	GuardMaintainer TransitionGuard = "maintainer"
+)
+
+func (g TransitionGuard) Valid() bool {
+	return g == "" || g == GuardAssignee || g == GuardMaintainer
+}
+
+// allows tells whether u may take a transition guarded by g on t. Admins
+// pass every guard, like they pass every project permission.
+func (g TransitionGuard) allows(ctx context.Context, store Store, orgID int64, u *User, t *Task) (bool, error) {
+	switch {
+	case g == "" || u.Role == RoleAdmin:
+		return true, nil
+	case g == GuardAssignee:
+		return u.ID == t.AssignedTo, nil
+	}
+
+	m, err := store.GetProjectMember(ctx, orgID, t.ProjectID, u.ID)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return m.Role == ProjectRoleOwner || m.Role == ProjectRoleMaintainer, nil
+}
+
+// Workflow is the state machine of the statuses of a project's tasks: a
+// task only changes its status along one of Transitions.
+type Workflow struct {
+	Transitions []WorkflowTransition
+	// Default is set for the default workflow of a project that didn't
+	// configure one
+	Default bool
+}
+
+// newWorkflow is the workflow of a project with statuses, ordered by
+// position, configured with transitions. Without transitions it is the
+// default workflow, which takes tasks through the statuses in order without
+// a way back. Transitions are ordered by status.
+func newWorkflow(transitions []WorkflowTransition, statuses []*ProjectStatus) Workflow {
+	index := func(id int64) int {
+		return slices.IndexFunc(statuses, func(st *ProjectStatus) bool { return st.ID == id })
+	}
+
+	if len(transitions) == 0 {
+		wf := Workflow{Transitions: []WorkflowTransition{}, Default: true}
+		for i := 1; i < len(statuses); i++ {
+			wf.Transitions = append(wf.Transitions, WorkflowTransition{FromStatusID: statuses[i-1].ID, ToStatusID: statuses[i].ID})
+		}
+
+		return wf
+	}
+
+	transitions = slices.Clone(transitions)
+	slices.SortFunc(transitions, func(a, b WorkflowTransition) int {
+		if a.FromStatusID != b.FromStatusID {
+			return index(a.FromStatusID) - index(b.FromStatusID)
+		}
+		return index(a.ToStatusID) - index(b.ToStatusID)
+	})
+
+	return Workflow{Transitions: transitions}
+}
+
+// Transition finds the transition from one status to another, false when
+// the workflow doesn't allow that change.
+func (wf Workflow) Transition(fromID, toID int64) (WorkflowTransition, bool) {
+	for _, tr := range wf.Transitions {
+		if tr.FromStatusID == fromID && tr.ToStatusID == toID {
+			return tr, true
+		}
+	}
+
+	return WorkflowTransition{}, false
+}
+
+func (s *ProjectService) HandleWorkflowGet(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectRead); !ok {
+		return
+	}
+
+	transitions, err := s.store.ListWorkflowTransitions(r.Context(), orgID, projectID)
+	if err != nil {
+		WriteStoreError(w, r, err, "workflow")
+		return
+	}
+
+	statuses, err := s.store.ListProjectStatuses(r.Context(), orgID, projectID)
+	if err != nil {
+		WriteStoreError(w, r, err, "workflow")
+		return
+	}
+
+	wf := newWorkflow(transitions, statuses)
+	WriteJSON(w, http.StatusOK, WorkflowResponse{Transitions: wf.Transitions, Default: wf.Default})
+}
+
+// HandleWorkflowReplace replaces the transitions of the project's workflow.
+// Tasks keep their status, even one no transition leads to anymore.
+func (s *ProjectService) HandleWorkflowReplace(w http.ResponseWriter, r *http.Request) {
+	orgID, ok := orgFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	projectID, ok := projectIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload WorkflowPayload
+	if !DecodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := validateWorkflowPayload(&payload); err != nil {
+		WriteValidationProblem(w, r, err)
+		return
+	}
+
+	if _, ok := authorizeProject(w, r, s.store, orgID, projectID, ProjectWorkflowManage); !ok {
+		return
+	}
+
+	err := s.store.ReplaceWorkflowTransitions(r.Context(), orgID, projectID, payload.Transitions)
+	if errors.Is(err, ErrNotFound) {
+		WriteStoreError(w, r, err, "project")
+		return
+	}
+
+	if errors.Is(err, ErrForeignKey) {
+		WriteProblem(w, r, http.StatusUnprocessableEntity, CodeInvalidReference, "transitions have to be between statuses of the project")
+		return
+	}
+
+	if err != nil {
+		WriteStoreError(w, r, err, "workflow")
+		return
+	}
+
+	statuses, err := s.store.ListProjectStatuses(r.Context(), orgID, projectID)
+	if err != nil {
+		WriteStoreError(w, r, err, "workflow")
+		return
+	}
+
+	wf := newWorkflow(payload.Transitions, statuses)
+	WriteJSON(w, http.StatusOK, WorkflowResponse{Transitions: wf.Transitions, Default: wf.Default})
+}
+
+func validateWorkflowPayload(payload *WorkflowPayload) error {
+	var v Validator
+	seen := make(map[[2]int64]bool)
+	for i, tr := range payload.Transitions {
+		field := "transitions[" + strconv.Itoa(i) + "]"
+		v.ID(field+".from_status_id", tr.FromStatusID).Required()
+		v.ID(field+".to_status_id", tr.ToStatusID).Required()
+		v.Check(field+".guard", tr.Guard.Valid(), "guard must be "+string(GuardAssignee)+" or "+string(GuardMaintainer))
+
+		key := [2]int64{tr.FromStatusID, tr.ToStatusID}
+		v.Check(field, tr.FromStatusID != tr.ToStatusID, "a transition has to change the status")
+		v.Check(field, tr.FromStatusID == tr.ToStatusID || !seen[key], "transitions can't repeat")
+		seen[key] = true
+	}
+
+	return v.Err()
+}
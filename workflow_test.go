@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestProjectWorkflow(t *testing.T) {
+	newStore := func() *MockStore {
+		return &MockStore{
+			projects: []*Project{{ID: 1, OrgID: testOrgID}},
+			members: []*ProjectMember{
+				{ProjectID: 1, UserID: 1, Role: ProjectRoleOwner},
+				{ProjectID: 1, UserID: 2, Role: ProjectRoleMaintainer},
+				{ProjectID: 1, UserID: 3, Role: ProjectRoleContributor},
+			},
+			statuses: statusesOf(1),
+		}
+	}
+
+	serve := func(t *testing.T, ms *MockStore, caller int64, method string, payload any) *httptest.ResponseRecorder {
+		router := http.NewServeMux()
+
+		service := NewProjectService(ms)
+		router.HandleFunc("GET /projects/{project_id}/workflow", service.HandleWorkflowGet)
+		router.HandleFunc("PUT /projects/{project_id}/workflow", service.HandleWorkflowReplace)
+
+		return serveAs(t, router, caller, method, "/projects/1/workflow", payload)
+	}
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) WorkflowResponse {
+		var res WorkflowResponse
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	t.Run("should start from the default workflow", func(t *testing.T) {
+		rr := serve(t, newStore(), 3, http.MethodGet, nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		// through the statuses in order
+		want := []WorkflowTransition{
+			{FromStatusID: 11, ToStatusID: 12},
+			{FromStatusID: 12, ToStatusID: 13},
+			{FromStatusID: 13, ToStatusID: 14},
+		}
+		if res := decode(t, rr); !res.Default || !reflect.DeepEqual(res.Transitions, want) {
+			t.Errorf("expected the default workflow %+v, got %+v", want, res)
+		}
+	})
+
+	t.Run("should replace the workflow and order it by status", func(t *testing.T) {
+		ms := newStore()
+		transitions := []WorkflowTransition{
+			{FromStatusID: 14, ToStatusID: 11, Guard: GuardMaintainer},
+			{FromStatusID: 11, ToStatusID: 14, Guard: GuardAssignee},
+		}
+
+		rr := serve(t, ms, 2, http.MethodPut, WorkflowPayload{Transitions: transitions})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		rr = serve(t, ms, 3, http.MethodGet, nil)
+		want := []WorkflowTransition{transitions[1], transitions[0]}
+		if res := decode(t, rr); res.Default || !reflect.DeepEqual(res.Transitions, want) {
+			t.Errorf("expected transitions %+v, got %+v", want, res)
+		}
+	})
+
+	t.Run("should restore the default workflow without transitions", func(t *testing.T) {
+		ms := newStore()
+		ms.workflows = map[int64][]WorkflowTransition{1: {{FromStatusID: 14, ToStatusID: 11}}}
+
+		rr := serve(t, ms, 1, http.MethodPut, WorkflowPayload{Transitions: []WorkflowTransition{}})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+		}
+
+		if res := decode(t, rr); !res.Default {
+			t.Errorf("expected the default workflow, got %+v", res)
+		}
+	})
+
+	t.Run("should forbid contributors from changing the workflow", func(t *testing.T) {
+		rr := serve(t, newStore(), 3, http.MethodPut, WorkflowPayload{Transitions: []WorkflowTransition{{FromStatusID: 14, ToStatusID: 11}}})
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, got %d", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should reject statuses of other projects", func(t *testing.T) {
+		ms := newStore()
+		ms.errs = map[string]error{"ReplaceWorkflowTransitions": ErrForeignKey}
+
+		rr := serve(t, ms, 1, http.MethodPut, WorkflowPayload{Transitions: []WorkflowTransition{{FromStatusID: 14, ToStatusID: 21}}})
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+	})
+
+	t.Run("should validate every transition", func(t *testing.T) {
+		rr := serve(t, newStore(), 1, http.MethodPut, WorkflowPayload{Transitions: []WorkflowTransition{
+			{FromStatusID: 11},
+			{FromStatusID: 14, ToStatusID: 14},
+			{FromStatusID: 11, ToStatusID: 14, Guard: "owner"},
+			{FromStatusID: 11, ToStatusID: 14},
+		}})
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+		}
+
+		var p Problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatal(err)
+		}
+
+		fields := []string{}
+		for _, f := range p.Errors {
+			fields = append(fields, f.Field)
+		}
+
+		want := []string{"transitions[0].to_status_id", "transitions[1]", "transitions[2].guard", "transitions[3]"}
+		if !reflect.DeepEqual(fields, want) {
+			t.Errorf("expected errors for %v, got %+v", want, p.Errors)
+		}
+	})
+}